@@ -0,0 +1,295 @@
+// Package bencode reads and writes bencode, the encoding used by the
+// nREPL protocol.
+//
+// Decoding produces the following Go values:
+//
+//	integers      int64
+//	strings       string
+//	lists         []interface{}
+//	dictionaries  map[string]interface{}
+//
+// Encoding also accepts the other integer types, []byte, []string and
+// map[string]string. Dictionary keys are written in sorted order as the
+// format requires.
+package bencode
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// SyntaxError is returned for input that isn't valid bencode
+type SyntaxError struct {
+	Offset int64
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("bencode: %s at offset %d", e.Msg, e.Offset)
+}
+
+// Marshal returns the bencoding of v
+func Marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := NewEncoder(&b).Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Unmarshal decodes a single bencoded value
+func Unmarshal(data []byte) (interface{}, error) {
+	d := NewDecoder(bytes.NewReader(data))
+	v, err := d.Decode()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.r.ReadByte(); err != io.EOF {
+		return nil, &SyntaxError{d.offset, "trailing data"}
+	}
+	return v, nil
+}
+
+// An Encoder writes bencoded values to a stream
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns an encoder that writes to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the bencoding of v. Nothing is written if v can't be
+// encoded.
+func (e *Encoder) Encode(v interface{}) error {
+	var b bytes.Buffer
+	if err := encode(&b, v); err != nil {
+		return err
+	}
+	_, err := e.w.Write(b.Bytes())
+	return err
+}
+
+func encode(b *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case int:
+		writeInt(b, int64(v))
+	case int32:
+		writeInt(b, int64(v))
+	case int64:
+		writeInt(b, v)
+	case uint:
+		writeInt(b, int64(v))
+	case string:
+		writeString(b, v)
+	case []byte:
+		writeString(b, string(v))
+	case []string:
+		b.WriteByte('l')
+		for _, s := range v {
+			writeString(b, s)
+		}
+		b.WriteByte('e')
+	case []interface{}:
+		b.WriteByte('l')
+		for _, x := range v {
+			if err := encode(b, x); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('e')
+	case map[string]string:
+		b.WriteByte('d')
+		for _, k := range sortedKeys(v) {
+			writeString(b, k)
+			writeString(b, v[k])
+		}
+		b.WriteByte('e')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteByte('d')
+		for _, k := range keys {
+			writeString(b, k)
+			if err := encode(b, v[k]); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('e')
+	default:
+		return fmt.Errorf("bencode: unsupported type %T", v)
+	}
+	return nil
+}
+
+func writeInt(b *bytes.Buffer, n int64) {
+	b.WriteByte('i')
+	b.WriteString(strconv.FormatInt(n, 10))
+	b.WriteByte('e')
+}
+
+func writeString(b *bytes.Buffer, s string) {
+	b.WriteString(strconv.Itoa(len(s)))
+	b.WriteByte(':')
+	b.WriteString(s)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// The longest string that a Decoder will accept
+const maxStringLen = 64 << 20
+
+// A Decoder reads bencoded values from a stream
+type Decoder struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+// NewDecoder returns a decoder that reads from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next value from the stream. It returns io.EOF if the
+// stream ends cleanly between values.
+func (d *Decoder) Decode() (interface{}, error) {
+	c, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	return d.decode(c)
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	c, err := d.r.ReadByte()
+	if err == nil {
+		d.offset++
+	}
+	return c, err
+}
+
+// next reads a byte in the middle of a value, where running out of input
+// is an error
+func (d *Decoder) next() (byte, error) {
+	c, err := d.readByte()
+	if err == io.EOF {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return c, err
+}
+
+func (d *Decoder) decode(c byte) (interface{}, error) {
+	switch {
+	case c == 'i':
+		return d.readInt('e')
+	case c >= '0' && c <= '9':
+		d.r.UnreadByte()
+		d.offset--
+		return d.readString()
+	case c == 'l':
+		list := make([]interface{}, 0)
+		for {
+			c, err := d.next()
+			if err != nil {
+				return nil, err
+			}
+			if c == 'e' {
+				return list, nil
+			}
+			v, err := d.decode(c)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+	case c == 'd':
+		dict := make(map[string]interface{})
+		for {
+			c, err := d.next()
+			if err != nil {
+				return nil, err
+			}
+			if c == 'e' {
+				return dict, nil
+			}
+			if c < '0' || c > '9' {
+				return nil, &SyntaxError{d.offset - 1, "dictionary keys must be strings"}
+			}
+			d.r.UnreadByte()
+			d.offset--
+			key, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+			c, err = d.next()
+			if err != nil {
+				return nil, err
+			}
+			if dict[key], err = d.decode(c); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, &SyntaxError{d.offset - 1, fmt.Sprintf("unexpected %q", c)}
+	}
+}
+
+// readInt reads the digits of an integer up to the terminator
+func (d *Decoder) readInt(end byte) (int64, error) {
+	start := d.offset
+	var digits []byte
+	for {
+		c, err := d.next()
+		if err != nil {
+			return 0, err
+		}
+		if c == end {
+			break
+		}
+		if len(digits) > 20 {
+			return 0, &SyntaxError{start, "integer too long"}
+		}
+		digits = append(digits, c)
+	}
+	n, err := strconv.ParseInt(string(digits), 10, 64)
+	if err != nil {
+		return 0, &SyntaxError{start, fmt.Sprintf("invalid integer %q", digits)}
+	}
+	return n, nil
+}
+
+func (d *Decoder) readString() (string, error) {
+	start := d.offset
+	n, err := d.readInt(':')
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || n > maxStringLen {
+		return "", &SyntaxError{start, fmt.Sprintf("invalid string length %d", n)}
+	}
+	buf := make([]byte, n)
+	read, err := io.ReadFull(d.r, buf)
+	d.offset += int64(read)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
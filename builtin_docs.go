@@ -0,0 +1,131 @@
+package gigl
+
+// The usage and docstring of each builtin registered in
+// newGlobalEnvironment. Optional arguments are shown in [brackets].
+var builtinDocs = map[SYMBOL][2]string{
+	"+":        {"(+ x y ...)", "Add together two or more numbers."},
+	"-":        {"(- x y ...)", "Subtract two or more numbers in succession."},
+	"*":        {"(* x y ...)", "Multiply two or more numbers in succession."},
+	"/":        {"(/ x y ...)", "Divide two or more numbers in succession."},
+	"%":        {"(% x y)", "The remainder on dividing x by y."},
+	"modulo":   {"(modulo x y)", "The remainder on dividing x by y."},
+	"<":        {"(< x y)", "True if x is less than y."},
+	"<=":       {"(<= x y)", "True if x is less than or equal to y."},
+	">":        {"(> x y)", "True if x is greater than y."},
+	">=":       {"(>= x y)", "True if x is greater than or equal to y."},
+	"=":        {"(= x y)", "True if the numbers x and y are equal."},
+	"!=":       {"(!= x y)", "True if the numbers x and y are not equal."},
+	"eq?":      {"(eq? x y)", "True if x and y are the same value."},
+	"null?":    {"(null? x)", "True for nil and the empty list."},
+	"nil?":     {"(nil? x)", "True if x is nil."},
+	"bool?":    {"(bool? x)", "True if x is #t or #f."},
+	"int?":     {"(int? x)", "True if x is a number with no fractional part."},
+	"float?":   {"(float? x)", "True if x is a number."},
+	"string?":  {"(string? x)", "True if x is a string."},
+	"symbol?":  {"(symbol? x)", "True if x is a symbol."},
+	"keyword?": {"(keyword? x)", "True if x is a keyword."},
+	"list?":    {"(list? x)", "True if x is a list containing at least one item."},
+	"pair?":    {"(pair? x)", "True if x is a non-empty list."},
+	"car":      {"(car lst)", "The first element of a list."},
+	"cdr":      {"(cdr lst)", "Everything but the first element of a list."},
+	"head":     {"(head lst)", "The first element of a list."},
+	"tail":     {"(tail lst)", "Everything but the first element of a list."},
+	"len":      {"(len x)", "The length of a list, or of a string in characters."},
+	"get": {"(get coll key [default])",
+		"Look up a key in a map, a member of a set or an index in a vector,\nreturning default (or nil) if it isn't there."},
+	"cons":   {"(cons x lst)", "A new list with x prepended to lst."},
+	"append": {"(append lst ...)", "A new list made of the elements of each list in turn."},
+	"range": {"(range [start] end [step])",
+		"The numbers from start (default 0) up to but not including end."},
+	"str": {"(str x)", "The readable form of a value as a string."},
+
+	"string-ref": {"(string-ref s i)", "The character at rune index i of s."},
+	"substring": {"(substring s start [end])",
+		"The characters of s from rune index start up to end."},
+	"string-split": {"(string-split s [sep])",
+		"Split s on sep, or on runs of whitespace if no separator is given."},
+	"string-join": {"(string-join strs [sep])",
+		"Join a list of strings with an optional separator."},
+	"string-replace": {"(string-replace s old new)", "Replace all occurrences of old in s with new."},
+	"upcase":         {"(upcase s)", "s in upper case."},
+	"downcase":       {"(downcase s)", "s in lower case."},
+	"trim":           {"(trim s)", "s without leading and trailing whitespace."},
+	"starts-with?":   {"(starts-with? s prefix)", "True if s starts with prefix."},
+	"ends-with?":     {"(ends-with? s suffix)", "True if s ends with suffix."},
+	"contains?":      {"(contains? s part)", "True if part appears in s."},
+	"format": {"(format fmt x ...)",
+		"Format a string using Go's fmt verbs: (format \"%s has %d items\" name n)\nInteger verbs such as %d need integral numbers."},
+	"string->number": {"(string->number s [radix])",
+		"Parse a number from a string, returning #f if it isn't one."},
+	"number->string": {"(number->string n [radix])",
+		"Render a number as a string, with an optional radix for integers."},
+
+	"char?":            {"(char? x)", "True if x is a character."},
+	"char->integer":    {"(char->integer c)", "The code point of a character."},
+	"integer->char":    {"(integer->char n)", "The character with code point n."},
+	"char-upcase":      {"(char-upcase c)", "c in upper case."},
+	"char-downcase":    {"(char-downcase c)", "c in lower case."},
+	"char-alphabetic?": {"(char-alphabetic? c)", "True if c is a letter."},
+	"char-numeric?":    {"(char-numeric? c)", "True if c is a decimal digit."},
+	"char-whitespace?": {"(char-whitespace? c)", "True if c is whitespace."},
+	"string->list":     {"(string->list s)", "Split a string into a list of characters."},
+	"list->string":     {"(list->string chars)", "Build a string from a list of characters."},
+
+	"regex?":     {"(regex? x)", "True if x is a regular expression."},
+	"re-pattern": {"(re-pattern s)", "Compile a string into a regular expression."},
+	"re-find": {"(re-find re s)",
+		"The first match of re in s, or nil. If re has groups the match is a\nvector of the whole match followed by the groups."},
+	"re-matches": {"(re-matches re s)", "Like re-find, but re has to match the whole of s."},
+	"re-seq":     {"(re-seq re s)", "A lazy sequence of all of the matches of re in s."},
+	"re-groups": {"(re-groups re s)",
+		"The capture groups of the first match of re in s as a map. Named\ngroups are keyed by keyword and all groups by their index."},
+	"re-replace": {"(re-replace re s replacement)",
+		"Replace every match of re in s. The replacement is either a string,\nwhich may refer to groups using $1 or ${name}, or a procedure that is\ncalled with each match and returns a string."},
+
+	"display": {"(display x [port])", "Write a value for humans."},
+	"write":   {"(write x [port])", "Write a value so that it can be read back in."},
+	"pp": {"(pp x [width])",
+		"Pretty print a value to the current output port."},
+	"print":               {"(print x ... [port])", "Display all arguments separated by spaces."},
+	"println":             {"(println x ... [port])", "Display all arguments separated by spaces, followed by a newline."},
+	"newline":             {"(newline [port])", "Write a newline."},
+	"read-line":           {"(read-line [port])", "Read a line of text without its line ending, or nil at end of input."},
+	"current-output-port": {"(current-output-port)", "The port that output is written to by default."},
+	"current-input-port":  {"(current-input-port)", "The port that input is read from by default."},
+	"input-port?":         {"(input-port? x)", "True if x is an input port."},
+	"output-port?":        {"(output-port? x)", "True if x is an output port."},
+	"open-input-file":     {"(open-input-file path)", "Open a file for reading."},
+	"open-output-file": {"(open-output-file path [mode])",
+		"Open a file for writing. Mode is :write, which replaces its contents\n(the default), or :append."},
+	"close-port": {"(close-port port)", "Close an input or output port."},
+	"slurp":      {"(slurp path)", "Read the entire contents of a file into a string."},
+	"spit": {"(spit path x [:append #t])",
+		"Write a value to a file, replacing its contents unless :append is set."},
+
+	"read": {"(read [port-or-string])",
+		"Read a form from a string, a port or the current input port."},
+	"read-string": {"(read-string s)", "Parse the first form in a string."},
+	"eval":        {"(eval form [env])", "Evaluate a form, in the global environment unless env is given."},
+	"make-environment": {"(make-environment [parent])",
+		"Create a new, empty environment inside parent or the global environment."},
+	"environment?": {"(environment? x)", "True if x is an environment."},
+	"features":     {"(features)", "List the optional features that are present."},
+	"apropos":      {"(apropos part)", "Every name containing a substring."},
+	"meta": {"(meta x)",
+		"The metadata of a procedure, or of the binding of a quoted symbol."},
+	"with-meta": {"(with-meta f m)", "A copy of the procedure f with the metadata m."},
+
+	"read-edn": {"(read-edn [port-or-string] [:readers {tag f}])",
+		"Read an EDN value from a string, a port or the current input port.\nTagged values are passed to the matching procedure in :readers."},
+	"write-edn": {"(write-edn x [port])", "Write a value as EDN."},
+	"json-parse": {"(json-parse s [:keys :keyword])",
+		"Parse a JSON document. Object keys are strings unless :keys is\n:keyword. Integers too large to hold exactly are an error."},
+	"json-stringify": {"(json-stringify x [:pretty #t] [:indent \"  \"])",
+		"Render a value as JSON."},
+	"json-seq": {"(json-seq [port-or-string] [:keys :keyword])",
+		"A lazy sequence of the JSON values in a stream, such as\nnewline delimited JSON."},
+	"csv-read": {"(csv-read path-or-port [:header #t] [:sep \",\"] [:numbers #f])",
+		"A lazy sequence of the rows of a CSV file. With a header row each row\nis a map keyed by the column names as keywords, otherwise it is a\nvector of strings. Files ending in .tsv are tab separated."},
+	"csv-write": {"(csv-write path-or-port rows [:header (:a :b)] [:sep \",\"])",
+		"Write a sequence of rows, either maps or sequences of fields, as CSV."},
+}
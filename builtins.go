@@ -6,6 +6,7 @@ import (
 	"math"
 	"math/big"
 	"reflect"
+	"unicode/utf8"
 )
 
 /*
@@ -140,28 +141,49 @@ func isList(lst ...lispVal) (lispVal, error) {
 	if len(lst) != 1 {
 		return nil, fmt.Errorf("Type check on non-atom: %v", lst)
 	}
-	_, ok := lst[0].(*LispList)
-	return ok, nil
+	switch lst[0].(type) {
+	case *LispList, *LazySeq:
+		return true, nil
+	default:
+		return false, nil
+	}
 }
 
 func isPair(lst ...lispVal) (lispVal, error) {
 	if len(lst) != 1 {
 		return nil, fmt.Errorf("Type check on non-atom: %v", lst)
 	}
-	l, ok := lst[0].(*LispList)
-	if !ok {
+	switch l := lst[0].(type) {
+	case *LispList:
+		return l.Len() > 0, nil
+	case *LazySeq:
+		empty, err := l.IsEmpty()
+		return !empty, err
+	default:
 		return false, nil
 	}
-	return l.Len() > 0, nil
 }
 
+func isNil(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("Type check on non-atom: %v", lst)
+	}
+	return lst[0] == nil, nil
+}
+
+// nil and the empty list are null, everything else is not
 func isNull(lst ...lispVal) (lispVal, error) {
-	list, ok := lst[0].(*LispList)
-	if !ok {
-		fmt.Println(list)
-		return nil, fmt.Errorf("null? can only be called on lists")
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("Type check on non-atom: %v", lst)
+	}
+	switch l := lst[0].(type) {
+	case nil:
+		return true, nil
+	case *LazySeq:
+		return l.IsEmpty()
+	default:
+		return isEmptyList(l), nil
 	}
-	return list.Len() == 0, nil
 }
 
 func str(lst ...lispVal) (lispVal, error) {
@@ -366,6 +388,12 @@ func lispAppend(lst ...lispVal) (lispVal, error) {
 		switch l.(type) {
 		case *LispList:
 			slices = append(slices, l.(*LispList).toSlice()...)
+		case *LazySeq:
+			vals, err := l.(*LazySeq).toSlice()
+			if err != nil {
+				return nil, err
+			}
+			slices = append(slices, vals...)
 		default:
 			return nil, fmt.Errorf("Arguments to append must lists")
 		}
@@ -376,34 +404,93 @@ func lispAppend(lst ...lispVal) (lispVal, error) {
 
 // return the first element of a list
 func car(lst ...lispVal) (lispVal, error) {
-	l, ok := lst[0].(*LispList)
-	if !ok {
+	switch l := lst[0].(type) {
+	case *LispList:
+		return l.Head(), nil
+	case *LazySeq:
+		if empty, err := l.IsEmpty(); empty || err != nil {
+			return &LispList{}, err
+		}
+		return l.First()
+	default:
 		return nil, fmt.Errorf("car called on an atom")
 	}
-	return l.Head(), nil
 }
 
 // everything but the first element of a list
 func cdr(lst ...lispVal) (lispVal, error) {
-	l, ok := lst[0].(*LispList)
-	if !ok {
+	switch l := lst[0].(type) {
+	case *LispList:
+		return l.Tail(), nil
+	case *LazySeq:
+		if empty, err := l.IsEmpty(); empty || err != nil {
+			return &LispList{}, err
+		}
+		return l.Rest()
+	default:
 		return nil, fmt.Errorf("cdr called on an atom")
 	}
-	return l.Tail(), nil
 }
 
-// length of a list
+// length of a list or string (in runes)
 func lispLength(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("len takes a single argument")
+	}
 	switch lst[0].(type) {
 	case string:
-		return len(lst[0].(string)), nil
+		return float64(utf8.RuneCountInString(lst[0].(string))), nil
 	case *LispList:
 		return float64(lst[0].(*LispList).Len()), nil
+	case *LazySeq:
+		vals, err := lst[0].(*LazySeq).toSlice()
+		if err != nil {
+			return nil, err
+		}
+		return float64(len(vals)), nil
 	default:
 		return nil, fmt.Errorf("len called on a non-sequence")
 	}
 }
 
+// look up a key in a map, a member of a set or an index in a vector:
+// (get coll key [default])
+func get(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 2 && len(lst) != 3 {
+		return nil, fmt.Errorf("get takes a collection, a key and an optional default")
+	}
+	var notFound lispVal
+	if len(lst) == 3 {
+		notFound = lst[2]
+	}
+
+	switch coll := lst[0].(type) {
+	case MAP:
+		if err := checkHashable(lst[1]); err != nil {
+			return nil, err
+		}
+		if v, ok := coll[lst[1]]; ok {
+			return v, nil
+		}
+	case SET:
+		if err := checkHashable(lst[1]); err != nil {
+			return nil, err
+		}
+		if coll[lst[1]] {
+			return lst[1], nil
+		}
+	case []lispVal, VECTOR:
+		vals, _ := seqToSlice("get", coll)
+		if i, err := getIndex(lst[1]); err == nil && i >= 0 && i < len(vals) {
+			return vals[i], nil
+		}
+	case nil:
+	default:
+		return nil, fmt.Errorf("get called on a non-collection: %v", String(coll))
+	}
+	return notFound, nil
+}
+
 /*
 	Sequence functions
 */
@@ -0,0 +1,88 @@
+package gigl
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+	REPL tab completion
+
+	Inside a string literal the text typed so far is completed as a file
+	path. Anywhere else the symbol before the cursor is completed from the
+	names bound in the environment, the macro table and the special forms.
+*/
+
+// replCompleter implements readline.AutoCompleter
+type replCompleter struct {
+	e *Evaluator
+}
+
+// Do returns the possible endings for the text before pos along with the
+// number of runes that they replace
+func (c replCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	before := string(line[:pos])
+
+	// Completion runs alongside the REPL so it gets its own tokeniser
+	t := NewTokeniser()
+	t.Tokenise(before)
+	if n := len(t.tokens); n > 0 && t.exhausted {
+		if last := t.tokens[n-1]; strings.HasPrefix(last.Text, `"`) {
+			return completePath(last.Text[1:])
+		}
+		return nil, 0
+	}
+
+	start := len(before)
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(before[:start])
+		if isDelimiter(r) {
+			break
+		}
+		start -= size
+	}
+	prefix := before[start:]
+	if prefix == "" {
+		return nil, 0
+	}
+
+	var candidates [][]rune
+	for _, name := range c.e.allNames(c.e.globalEnv) {
+		if strings.HasPrefix(name, prefix) {
+			candidates = append(candidates, []rune(name[len(prefix):]))
+		}
+	}
+	return candidates, len([]rune(prefix))
+}
+
+// completePath lists the files that could complete a partial path
+func completePath(partial string) ([][]rune, int) {
+	dir, base := filepath.Split(partial)
+	entries, err := os.ReadDir(filepath.Clean(dir + "."))
+	if err != nil {
+		return nil, 0
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		// Hidden files are only offered once a dot has been typed
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name[len(base):])
+	}
+	sort.Strings(names)
+
+	candidates := make([][]rune, len(names))
+	for i, name := range names {
+		candidates[i] = []rune(name)
+	}
+	return candidates, len([]rune(base))
+}
@@ -0,0 +1,98 @@
+package gigl
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// completions runs the REPL completer with the cursor at the end of line
+func completions(e *Evaluator, line string) ([]string, int) {
+	runes := []rune(line)
+	candidates, n := replCompleter{e}.Do(runes, len(runes))
+	var got []string
+	for _, c := range candidates {
+		got = append(got, string(c))
+	}
+	sort.Strings(got)
+	return got, n
+}
+
+func TestCompleteSymbols(t *testing.T) {
+	e := newTestEvaluator(t)
+	src := `(define my-value 1) (defn my-double (x) (* x 2)) (defmacro my-macro (x) x) (define λ-thing 2)`
+	if _, err := evalSource(e, src); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line string
+		want []string
+		n    int
+	}{
+		// Global definitions, macros, builtins and special forms
+		{"(my-", []string{"double", "macro", "value"}, 3},
+		{"(my-d", []string{"ouble"}, 4},
+		{"(string->n", []string{"umber"}, 9},
+		{"(car", []string{""}, 3},
+		{"(quasi", []string{"quote"}, 5},
+		{"(with-open-f", []string{"ile"}, 11},
+		{"λ-", []string{"thing"}, 2},
+
+		// Only the symbol before the cursor is completed
+		{"(+ 1 my-v", []string{"alue"}, 4},
+		{"[my-v", []string{"alue"}, 4},
+		{"'my-v", []string{"alue"}, 4},
+		{"(f,my-v", []string{"alue"}, 4},
+
+		// Nothing to complete
+		{"", nil, 0},
+		{"(", nil, 0},
+		{"(car ", nil, 0},
+		{"(no-such-", nil, 8},
+		{"#| my-", nil, 0},
+	}
+
+	for _, tt := range tests {
+		got, n := completions(e, tt.line)
+		if !reflect.DeepEqual(got, tt.want) || n != tt.n {
+			t.Errorf("%q: got %q replacing %d, want %q replacing %d", tt.line, got, n, tt.want, tt.n)
+		}
+	}
+}
+
+func TestCompletePaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"data.csv", "data.tsv", "notes.txt", ".hidden"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	dir = filepath.ToSlash(dir) + "/"
+
+	tests := []struct {
+		line string
+		want []string
+		n    int
+	}{
+		{`(load "` + dir + `da`, []string{"ta.csv", "ta.tsv"}, 2},
+		{`(load "` + dir + `data.c`, []string{"sv"}, 6},
+		{`(load "` + dir + `s`, []string{"ub/"}, 1},
+		{`(load "` + dir, []string{"data.csv", "data.tsv", "notes.txt", "sub/"}, 0},
+		{`(load "` + dir + `.`, []string{"hidden"}, 1},
+		{`(load "` + dir + `missing/`, nil, 0},
+	}
+
+	e := newTestEvaluator(t)
+	for _, tt := range tests {
+		got, n := completions(e, tt.line)
+		if !reflect.DeepEqual(got, tt.want) || n != tt.n {
+			t.Errorf("%q: got %q replacing %d, want %q replacing %d", tt.line, got, n, tt.want, tt.n)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package gigl
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	Concrete syntax trees
+
+	The normal reader throws away everything that doesn't change the value
+	being read. Source tools (the formatter, linters, editor integration)
+	need the rest as well, so in CST mode the tokeniser keeps whitespace,
+	commas and comments ("trivia") and every node records exactly where it
+	came from in the source.
+
+	Trivia is attached to nodes in the same way as most other CST libraries:
+	everything after a node up to and including the end of its line is
+	trailing trivia of that node, while everything else belongs to the next
+	node as leading trivia. This means that a comment at the end of a line
+	stays with the code before it and a comment on a line of its own stays
+	with the code after it. Trivia before a closing bracket or at the end of
+	the file has no node to attach to so it is kept in CloseLeading.
+
+	A CST can be turned back into its source text exactly with Source, and
+	lowered to the same values that the normal reader produces with Lower.
+*/
+
+// Pos is a position in source text. Lines and columns start at 1 and
+// columns count runes.
+type Pos struct {
+	Offset int
+	Line   int
+	Col    int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// advance returns the position after the given text
+func (p Pos) advance(text string) Pos {
+	for _, r := range text {
+		if r == '\n' {
+			p.Line++
+			p.Col = 1
+		} else {
+			p.Col++
+		}
+	}
+	p.Offset += len(text)
+	return p
+}
+
+// Span is the range of source text that a token or node came from
+type Span struct {
+	Start Pos
+	End   Pos
+}
+
+// Trivia is source text that has no effect on the value being read:
+// WHITESPACE, NEWLINE, COMMA or COMMENT.
+type Trivia struct {
+	Kind string
+	Text string
+	Span Span
+}
+
+// CSTNode is a node in a concrete syntax tree
+type CSTNode struct {
+	Kind string // the token tag of an atom, a reader macro or LIST, VECTOR, MAP, SET, QUOTE or FILE
+	Text string // the source text of an atom, or the opening bracket or quote of a form
+	Span Span   // from the start of the opening token to the end of the closing one
+
+	Leading      []Trivia
+	Trailing     []Trivia
+	Children     []*CSTNode
+	CloseLeading []Trivia // trivia between the last child and the closing bracket
+	CloseText    string
+
+	// The child that a reader conditional selected, if any
+	chosen *CSTNode
+}
+
+// The kind of node for each type of collection
+var cstCollections = map[string]string{
+	"LIST_START": "LIST",
+	"VEC_START":  "VECTOR",
+	"MAP_START":  "MAP",
+	"SET_START":  "SET",
+}
+
+// ReadCST parses source text into a concrete syntax tree. The root of the
+// tree is a FILE node with each top level form as a child.
+func (t *Tokeniser) ReadCST(src string) (*CSTNode, error) {
+	keep := t.keepTrivia
+	t.keepTrivia = true
+	t.Tokenise(src)
+	t.keepTrivia = keep
+
+	root := &CSTNode{Kind: "FILE"}
+	for {
+		child, leading, err := t.parseCSTNode()
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			if t.ix < len(t.tokens) {
+				tok := t.tokens[t.ix]
+				return nil, &SyntaxError{Span: tok.Span, Message: fmt.Sprintf("Unexpected `%s`", tok.Text)}
+			}
+			root.CloseLeading = leading
+			break
+		}
+		root.Children = append(root.Children, child)
+	}
+	root.Span = Span{Pos{Offset: 0, Line: 1, Col: 1}, Pos{Offset: 0, Line: 1, Col: 1}.advance(src)}
+	return root, nil
+}
+
+// collectTrivia takes trivia tokens from the stream while keep returns true
+func (t *Tokeniser) collectTrivia(keep func(tok token) bool) []Trivia {
+	var trivia []Trivia
+	for t.ix < len(t.tokens) && isTrivia(t.tokens[t.ix].Tag) && keep(t.tokens[t.ix]) {
+		tok := t.tokens[t.ix]
+		trivia = append(trivia, Trivia{Kind: tok.Tag, Text: tok.Text, Span: tok.Span})
+		t.ix++
+	}
+	return trivia
+}
+
+// collectTrailing takes trivia up to and including the end of the line
+func (t *Tokeniser) collectTrailing() []Trivia {
+	done := false
+	return t.collectTrivia(func(tok token) bool {
+		if done {
+			return false
+		}
+		done = tok.Tag == "NEWLINE"
+		return true
+	})
+}
+
+// parseCSTNode reads the next node from the token stream. If there are no
+// nodes left before a closing bracket or the end of the input then the node
+// is nil and the leading trivia is returned for the caller to keep.
+func (t *Tokeniser) parseCSTNode() (*CSTNode, []Trivia, error) {
+	leading := t.collectTrivia(func(token) bool { return true })
+	if t.ix >= len(t.tokens) {
+		return nil, leading, nil
+	}
+
+	tok := t.tokens[t.ix]
+	node := &CSTNode{Kind: tok.Tag, Text: tok.Text, Span: tok.Span, Leading: leading}
+
+	switch tok.Tag {
+	case "LIST_END", "VEC_END", "MAP_OR_SET_END":
+		return nil, leading, nil
+
+	case "QUOTE", "SPLICE":
+		t.ix++
+		nodes, form, err := t.parseCSTForm()
+		if err != nil {
+			return nil, nil, err
+		}
+		if form == nil {
+			return nil, nil, &SyntaxError{Span: tok.Span, Message: fmt.Sprintf("Missing form after `%s`", tok.Text)}
+		}
+		// The quote and the form are a single node as far as trivia goes
+		node.Kind = "QUOTE"
+		node.Children = nodes
+		node.Span.End = form.Span.End
+		node.Trailing, form.Trailing = form.Trailing, nil
+		return node, nil, nil
+
+	case "DATUM_COMMENT", "FEATURE_COND", "READER_COND":
+		t.ix++
+		if err := t.parseReaderMacro(node); err != nil {
+			return nil, nil, err
+		}
+		return node, nil, nil
+
+	case "LIST_START", "VEC_START", "MAP_START", "SET_START":
+		t.ix++
+		node.Kind = cstCollections[tok.Tag]
+		for {
+			child, closeLeading, err := t.parseCSTNode()
+			if err != nil {
+				return nil, nil, err
+			}
+			if child != nil {
+				node.Children = append(node.Children, child)
+				continue
+			}
+
+			if t.ix >= len(t.tokens) {
+				return nil, nil, &SyntaxError{Span: tok.Span, Message: fmt.Sprintf("Unclosed `%s` opened", tok.Text)}
+			}
+			end := t.tokens[t.ix]
+			if expected := closingBrackets[tok.Tag]; end.Tag != expected.tag {
+				msg := fmt.Sprintf("Unexpected `%s`, expected `%s`", end.Text, expected.text)
+				return nil, nil, &SyntaxError{Span: end.Span, Message: msg}
+			}
+			t.ix++
+			node.CloseLeading = closeLeading
+			node.CloseText = end.Text
+			node.Span.End = end.Span.End
+			break
+		}
+
+	case "ERROR":
+		return nil, nil, &SyntaxError{Span: errorTokenSpan(tok), Message: errorTokenMessage(tok)}
+
+	default:
+		t.ix++
+		// Make sure that the atom is valid
+		if _, err := makeAtom(tok); err != nil {
+			return nil, nil, &SyntaxError{Span: tok.Span, Message: err.Error()}
+		}
+	}
+
+	node.Trailing = t.collectTrailing()
+	return node, nil, nil
+}
+
+// parseCSTForm reads nodes up to and including the next one that the
+// reader would produce a value for. Like parseForm it passes over datum
+// comments and reader conditionals that don't apply, so #_ #_ a b skips
+// both a and b. Every node read is returned along with the form, which is
+// nil if there wasn't one before a closing bracket or the end of the input.
+func (t *Tokeniser) parseCSTForm() ([]*CSTNode, *CSTNode, error) {
+	var nodes []*CSTNode
+	for {
+		child, _, err := t.parseCSTNode()
+		if err != nil || child == nil {
+			return nodes, nil, err
+		}
+		nodes = append(nodes, child)
+		if child.reads() {
+			return nodes, child, nil
+		}
+	}
+}
+
+// parseReaderMacro reads the forms following #_, #+, #- or #? and works out
+// which of them (if any) the reader would have read
+func (t *Tokeniser) parseReaderMacro(node *CSTNode) error {
+	count := 1
+	if node.Kind == "FEATURE_COND" {
+		count = 2
+	}
+	forms := make([]*CSTNode, 0, count)
+	for i := 0; i < count; i++ {
+		var nodes []*CSTNode
+		var form *CSTNode
+		var err error
+		if node.Kind == "READER_COND" {
+			// The branches have to follow #? directly
+			form, _, err = t.parseCSTNode()
+			nodes = []*CSTNode{form}
+		} else {
+			nodes, form, err = t.parseCSTForm()
+		}
+		if err != nil {
+			return err
+		}
+		if form == nil {
+			return &SyntaxError{Span: node.Span, Message: fmt.Sprintf("Missing form after `%s`", node.Text)}
+		}
+		node.Children = append(node.Children, nodes...)
+		forms = append(forms, form)
+	}
+	last := forms[count-1]
+	node.Span.End = last.Span.End
+	node.Trailing, last.Trailing = last.Trailing, nil
+
+	switch node.Kind {
+	case "FEATURE_COND":
+		expr, err := forms[0].Lower()
+		if err != nil {
+			return err
+		}
+		found, err := t.hasFeature(expr)
+		if err != nil {
+			return &SyntaxError{Span: node.Span, Message: err.Error()}
+		}
+		if found == (node.Text == "#+") {
+			node.chosen = forms[1]
+		}
+
+	case "READER_COND":
+		branches := forms[0]
+		if branches.Kind != "LIST" {
+			return &SyntaxError{Span: node.Span, Message: "`#?` must be followed by a list"}
+		}
+		vals, nodes, err := branches.lowerChildren()
+		if err != nil {
+			return err
+		}
+		i, err := t.selectBranch(vals)
+		if err != nil {
+			return &SyntaxError{Span: node.Span, Message: err.Error()}
+		}
+		if i >= 0 {
+			node.chosen = nodes[i]
+		}
+	}
+	return nil
+}
+
+// Source reconstructs the exact source text that the node was read from
+func (n *CSTNode) Source() string {
+	var b strings.Builder
+	n.writeSource(&b)
+	return b.String()
+}
+
+func (n *CSTNode) writeSource(b *strings.Builder) {
+	writeTrivia(b, n.Leading)
+	if n.Kind != "FILE" {
+		b.WriteString(n.Text)
+	}
+	for _, child := range n.Children {
+		child.writeSource(b)
+	}
+	writeTrivia(b, n.CloseLeading)
+	b.WriteString(n.CloseText)
+	writeTrivia(b, n.Trailing)
+}
+
+func writeTrivia(b *strings.Builder, trivia []Trivia) {
+	for _, t := range trivia {
+		b.WriteString(t.Text)
+	}
+}
+
+// Comments returns the comments attached to the node itself: those on the
+// lines before it and one at the end of its last line.
+func (n *CSTNode) Comments() []Trivia {
+	var comments []Trivia
+	for _, t := range append(append([]Trivia{}, n.Leading...), n.Trailing...) {
+		if isComment(t.Kind) {
+			comments = append(comments, t)
+		}
+	}
+	return comments
+}
+
+// TrailingComment returns the comment at the end of the node's last line
+func (n *CSTNode) TrailingComment() (Trivia, bool) {
+	for _, t := range n.Trailing {
+		if isComment(t.Kind) {
+			return t, true
+		}
+	}
+	return Trivia{}, false
+}
+
+// Lower converts the node into the value that the normal reader would have
+// produced. A FILE lowers to a begin form containing each top level form.
+// Nodes that the reader skips, such as datum comments, lower to nil.
+func (n *CSTNode) Lower() (lispVal, error) {
+	switch n.Kind {
+	case "DATUM_COMMENT":
+		return nil, nil
+	case "FEATURE_COND", "READER_COND":
+		if n.chosen == nil {
+			return nil, nil
+		}
+		return n.chosen.Lower()
+	}
+
+	vals, _, err := n.lowerChildren()
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Kind {
+	case "FILE":
+		return consInternal(SYMBOL("begin"), List(vals...)), nil
+	case "LIST":
+		return List(vals...), nil
+	case "VECTOR":
+		return vals, nil
+	case "MAP":
+		return makeMap(vals)
+	case "SET":
+		return makeSet(vals)
+	case "QUOTE":
+		if len(vals) == 0 {
+			return nil, &SyntaxError{Span: n.Span, Message: fmt.Sprintf("Missing form after `%s`", n.Text)}
+		}
+		return List(quotes[n.Text], vals[0]), nil
+	default:
+		return makeAtom(token{Tag: n.Kind, Text: n.Text, Span: n.Span})
+	}
+}
+
+// reads reports whether the reader produces a value for the node
+func (n *CSTNode) reads() bool {
+	switch n.Kind {
+	case "DATUM_COMMENT":
+		return false
+	case "FEATURE_COND", "READER_COND":
+		return n.chosen != nil
+	}
+	return true
+}
+
+// lowerChildren lowers the children that the reader produces values for,
+// returning the nodes that each value came from alongside the values.
+func (n *CSTNode) lowerChildren() ([]lispVal, []*CSTNode, error) {
+	vals := make([]lispVal, 0, len(n.Children))
+	nodes := make([]*CSTNode, 0, len(n.Children))
+	for _, child := range n.Children {
+		if !child.reads() {
+			continue
+		}
+		v, err := child.Lower()
+		if err != nil {
+			return nil, nil, err
+		}
+		vals = append(vals, v)
+		nodes = append(nodes, child)
+	}
+	return vals, nodes, nil
+}
+
+// NodeAt returns the innermost node whose span contains the given offset
+func (n *CSTNode) NodeAt(offset int) *CSTNode {
+	for _, child := range n.Children {
+		if child.Span.Start.Offset <= offset && offset < child.Span.End.Offset {
+			return child.NodeAt(offset)
+		}
+	}
+	return n
+}
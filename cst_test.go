@@ -0,0 +1,131 @@
+package gigl
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// cstSources returns the example file and each prelude source, keyed by a
+// name to report failures with
+func cstSources(t *testing.T) map[string]string {
+	t.Helper()
+	examples, err := os.ReadFile(filepath.Join("examples", "examples.ggl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := map[string]string{"examples.ggl": string(examples)}
+	for i, src := range prelude {
+		sources["prelude "+strconv.Itoa(i)] = src
+	}
+	return sources
+}
+
+// lowerSource reads src as a CST and lowers each top level form
+func lowerSource(src string) ([]lispVal, error) {
+	root, err := NewTokeniser().ReadCST(src)
+	if err != nil {
+		return nil, err
+	}
+	vals, _, err := root.lowerChildren()
+	return vals, err
+}
+
+// checkLowersLikeReadAll checks that the CST for src lowers to the same
+// values that the reader produces, or that both of them fail
+func checkLowersLikeReadAll(t *testing.T, name, src string) {
+	t.Helper()
+	want, readErr := NewTokeniser().ReadAll(src)
+	got, lowerErr := lowerSource(src)
+	if (readErr == nil) != (lowerErr == nil) {
+		t.Errorf("%s: ReadAll gave error %v but Lower gave %v", name, readErr, lowerErr)
+		return
+	}
+	if readErr != nil {
+		return
+	}
+	if len(want) == 0 && len(got) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s: Lower gave %s, ReadAll gave %s", name, String(List(got...)), String(List(want...)))
+	}
+}
+
+func TestLowerReaderMacros(t *testing.T) {
+	tests := []string{
+		"#_ a b",
+		"#_ #_ a b c",
+		"#_#_#_ a b c d",
+		"#_ (a (b)) c",
+		"(1 #_ 2 3)",
+		"(1 #_ #_ 2 3 4)",
+		"(#_ a)",
+		"[#_ x]",
+		"{:a #_ :b 1}",
+		"'#_ a b",
+		"'#_ #_ a b c",
+		"`(a ~#_ b c)",
+		"#+gigl a b",
+		"#-gigl a b",
+		"#+clj a b",
+		"#+gigl #_ a b c",
+		"#+ #_ clj gigl a",
+		"#_ #+gigl a b c",
+		"#_ #-gigl a b c",
+		"#+gigl #+gigl a b",
+		"#+(or gigl clj) x",
+		"#+(and gigl (not clj)) [1 2]",
+		"#?(:gigl 1 :default 2)",
+		"#?(:clj 1) x",
+		"#_ #?(:clj 1) a b",
+		"(list #?(:clj 1 :gigl 2) #_ 3)",
+
+		// Errors
+		"#_",
+		"#_ #_ a",
+		"(#_)",
+		"'#_ a",
+		"#+gigl",
+		"#+gigl #_ a",
+		"#? #_ x (:gigl 1)",
+	}
+
+	for _, src := range tests {
+		checkLowersLikeReadAll(t, src, src)
+	}
+}
+
+func TestCSTSource(t *testing.T) {
+	valid := cstSources(t)
+	valid["trivia"] = "  ; leading\n(a ,b\t#| block |#\n  c) ; trailing\n\n#_ x 'y\n; at the end"
+	sources := make(map[string]string)
+	for name, src := range valid {
+		sources[name] = src
+	}
+	for _, src := range lexerSeeds {
+		sources[strconv.Quote(src)] = src
+	}
+
+	for name, src := range sources {
+		root, err := NewTokeniser().ReadCST(src)
+		if err != nil {
+			// Some of the lexer seeds are deliberately broken
+			if _, ok := valid[name]; ok {
+				t.Errorf("%s: %v", name, err)
+			}
+			continue
+		}
+		if got := root.Source(); got != src {
+			t.Errorf("%s: Source doesn't match the input:\n%s", name, got)
+		}
+	}
+}
+
+func TestCSTLowersLikeReadAll(t *testing.T) {
+	for name, src := range cstSources(t) {
+		checkLowersLikeReadAll(t, name, src)
+	}
+}
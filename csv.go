@@ -0,0 +1,314 @@
+package gigl
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+	CSV and TSV
+
+	csv-read streams rows from a file or port as a lazy sequence so that
+	large files never have to fit in memory: the file is closed once the
+	last row has been read. With a header row (the default) each row is a
+	MAP keyed by the header names as keywords, otherwise it is a vector of
+	strings. Fields are left as strings unless :numbers is set.
+
+	Files ending in .tsv are tab separated unless :sep says otherwise.
+*/
+
+// keywordArgs pulls :key value pairs off the end of an argument list after
+// nargs positional arguments, rejecting any key that isn't allowed. Every
+// builtin that takes options takes them this way.
+func keywordArgs(name string, lst []lispVal, nargs int, allowed ...KEYWORD) (map[KEYWORD]lispVal, error) {
+	if len(lst) < nargs || (len(lst)-nargs)%2 != 0 {
+		return nil, fmt.Errorf("%s takes %d arguments followed by :key value options", name, nargs)
+	}
+	opts := make(map[KEYWORD]lispVal)
+	for i := nargs; i < len(lst); i += 2 {
+		key, ok := lst[i].(KEYWORD)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected a keyword option: %v", name, String(lst[i]))
+		}
+		known := false
+		for _, k := range allowed {
+			known = known || k == key
+		}
+		if !known {
+			return nil, fmt.Errorf("Unknown option to %s: %v", name, String(key))
+		}
+		opts[key] = lst[i+1]
+	}
+	return opts, nil
+}
+
+// csvSeparator picks the field separator from a :sep option or the path
+func csvSeparator(name string, opts map[KEYWORD]lispVal, path string) (rune, error) {
+	sep, given := opts["sep"]
+	if !given {
+		if strings.HasSuffix(path, ".tsv") {
+			return '\t', nil
+		}
+		return ',', nil
+	}
+	s, err := getString(sep)
+	if err != nil {
+		return 0, err
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, fmt.Errorf("%s: :sep must be a single character: %v", name, String(sep))
+	}
+	// These are the separators that encoding/csv rejects, which it would
+	// otherwise only report once the first row is read
+	r, _ := utf8.DecodeRuneInString(s)
+	if r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("%s: invalid :sep: %v", name, String(sep))
+	}
+	return r, nil
+}
+
+// csvField converts a field to a number if it looks like one
+func csvField(s string, numbers bool) lispVal {
+	if !numbers || s == "" {
+		return s
+	}
+	if !isDigit(s[0]) && !(s[0] == '-' && len(s) > 1 && isDigit(s[1])) {
+		return s
+	}
+	if tag := numberTag(s); tag == "INT" || tag == "FLOAT" {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
+// read a CSV file or port as a lazy sequence of rows:
+// (csv-read path-or-port [:header #t] [:sep ","] [:numbers #f])
+func csvRead(lst ...lispVal) (lispVal, error) {
+	opts, err := keywordArgs("csv-read", lst, 1, "header", "sep", "numbers")
+	if err != nil {
+		return nil, err
+	}
+
+	var port *InputPort
+	path := ""
+	switch src := lst[0].(type) {
+	case string:
+		p, err := openFilePort(src, "read")
+		if err != nil {
+			return nil, err
+		}
+		port, path = p.(*InputPort), src
+	case *InputPort:
+		port = src
+	default:
+		return nil, fmt.Errorf("csv-read: expected a path or an input port: %v", String(src))
+	}
+
+	sep, err := csvSeparator("csv-read", opts, path)
+	if err != nil {
+		return nil, err
+	}
+	r := csv.NewReader(port)
+	r.Comma = sep
+	if sep == '\t' {
+		// Quotes in TSV files are usually just part of the text
+		r.LazyQuotes = true
+	}
+
+	header, hasHeader := opts["header"]
+	useHeader := !hasHeader || isTruthy(header)
+	numbers := isTruthy(opts["numbers"])
+	var keys []KEYWORD
+
+	return NewLazySeq(func() (lispVal, bool, error) {
+		record, err := r.Read()
+		if err == nil && useHeader && keys == nil {
+			keys = make([]KEYWORD, len(record))
+			for i, name := range record {
+				keys[i] = KEYWORD(strings.TrimSpace(name))
+			}
+			record, err = r.Read()
+		}
+		if err != nil {
+			// Only close ports that we opened ourselves
+			if path != "" {
+				port.Close()
+			}
+			if err == io.EOF {
+				return nil, false, nil
+			}
+			return nil, false, fmt.Errorf("csv-read: %v", err)
+		}
+
+		if !useHeader {
+			row := make([]lispVal, len(record))
+			for i, field := range record {
+				row[i] = csvField(field, numbers)
+			}
+			return row, true, nil
+		}
+		row := make(MAP, len(keys))
+		for i, field := range record {
+			row[keys[i]] = csvField(field, numbers)
+		}
+		return row, true, nil
+	}), nil
+}
+
+// csvText converts a value to the text of a field
+func csvText(v lispVal) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case KEYWORD:
+		return string(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return Display(v)
+	}
+}
+
+// write a sequence of rows (maps or sequences of fields) as CSV:
+// (csv-write path-or-port rows [:header (:a :b)] [:sep ","])
+// Rows that are maps are written with a header row. The columns are the
+// :header keys if given, otherwise the sorted keys of the first row.
+func csvWrite(lst ...lispVal) (lispVal, error) {
+	opts, err := keywordArgs("csv-write", lst, 2, "header", "sep")
+	if err != nil {
+		return nil, err
+	}
+
+	var port *OutputPort
+	path := ""
+	switch dst := lst[0].(type) {
+	case string:
+		p, err := openFilePort(dst, "write")
+		if err != nil {
+			return nil, err
+		}
+		port, path = p.(*OutputPort), dst
+		defer port.Close()
+	case *OutputPort:
+		port = dst
+	default:
+		return nil, fmt.Errorf("csv-write: expected a path or an output port: %v", String(dst))
+	}
+
+	sep, err := csvSeparator("csv-write", opts, path)
+	if err != nil {
+		return nil, err
+	}
+	w := csv.NewWriter(port.writer)
+	w.Comma = sep
+
+	var keys []lispVal
+	if header, ok := opts["header"]; ok {
+		if keys, err = seqToSlice("csv-write", header); err != nil {
+			return nil, err
+		}
+	}
+
+	first := true
+	err = eachInSeq("csv-write", lst[1], func(row lispVal) error {
+		m, isMap := row.(MAP)
+		if first {
+			first = false
+			if isMap && keys == nil {
+				for k := range m {
+					keys = append(keys, k)
+				}
+				sort.Slice(keys, func(i, j int) bool { return csvText(keys[i]) < csvText(keys[j]) })
+			}
+			if keys != nil {
+				if err := w.Write(csvRecord(keys)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if !isMap {
+			fields, err := seqToSlice("csv-write", row)
+			if err != nil {
+				return err
+			}
+			return w.Write(csvRecord(fields))
+		}
+		if keys == nil {
+			return fmt.Errorf("csv-write: map rows need a :header: %v", String(row))
+		}
+		fields := make([]lispVal, len(keys))
+		for i, k := range keys {
+			fields[i] = m[k]
+		}
+		return w.Write(csvRecord(fields))
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return nil, w.Error()
+}
+
+func csvRecord(fields []lispVal) []string {
+	record := make([]string, len(fields))
+	for i, field := range fields {
+		record[i] = csvText(field)
+	}
+	return record
+}
+
+// seqToSlice realises a list, vector or lazy sequence
+func seqToSlice(name string, v lispVal) ([]lispVal, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case *LispList:
+		return v.toSlice(), nil
+	case []lispVal:
+		return v, nil
+	case VECTOR:
+		return v, nil
+	case *LazySeq:
+		return v.toSlice()
+	default:
+		return nil, fmt.Errorf("%s: expected a sequence: %v", name, String(v))
+	}
+}
+
+// eachInSeq calls f for each element of a sequence, realising lazy
+// sequences one element at a time so that they don't have to fit in memory
+func eachInSeq(name string, v lispVal, f func(lispVal) error) error {
+	if seq, ok := v.(*LazySeq); ok {
+		for {
+			empty, err := seq.IsEmpty()
+			if err != nil || empty {
+				return err
+			}
+			if err := f(seq.head); err != nil {
+				return err
+			}
+			seq = seq.tail
+		}
+	}
+
+	vals, err := seqToSlice(name, v)
+	if err != nil {
+		return err
+	}
+	for _, val := range vals {
+		if err := f(val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package gigl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// csvFiles writes each file to a temporary directory, returning a function
+// that replaces DIR in a source string with the directory's path
+func csvFiles(t *testing.T, files map[string]string) func(string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func(src string) string {
+		return strings.ReplaceAll(src, "DIR", filepath.ToSlash(dir))
+	}
+}
+
+func TestCSVRead(t *testing.T) {
+	inDir := csvFiles(t, map[string]string{
+		"people.csv": "name, age\nann,41\nbob,-7.5\n",
+		"people.tsv": "name\tquote\nann\tsays \"hi\"\n",
+		"semi.txt":   "a;b\n1;2\n",
+		"commas.tsv": "a,b\n1,2\n",
+		"empty.csv":  "",
+		"ragged.csv": "a,b\n1,2\n3\n",
+	})
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`(csv-read "DIR/people.csv")`, `({:age "41", :name "ann"} {:age "-7.5", :name "bob"})`},
+		{`(csv-read "DIR/people.csv" :numbers #t)`, `({:age 41, :name "ann"} {:age -7.5, :name "bob"})`},
+		{`(csv-read "DIR/people.csv" :header #f)`, `(["name" " age"] ["ann" "41"] ["bob" "-7.5"])`},
+		{`(csv-read "DIR/people.csv" :header #f :numbers #t)`, `(["name" " age"] ["ann" 41] ["bob" -7.5])`},
+		{`(get (head (csv-read "DIR/people.csv")) :name)`, `"ann"`},
+
+		// .tsv files are tab separated and quotes are just text
+		{`(csv-read "DIR/people.tsv")`, `({:name "ann", :quote "says \"hi\""})`},
+		{`(csv-read "DIR/commas.tsv" :sep ",")`, `({:a "1", :b "2"})`},
+		{`(csv-read "DIR/semi.txt" :header #f)`, `(["a;b"] ["1;2"])`},
+		{`(csv-read "DIR/semi.txt" :sep ";")`, `({:a "1", :b "2"})`},
+
+		{`(csv-read "DIR/empty.csv")`, `()`},
+		{`(null? (csv-read "DIR/empty.csv" :header #f))`, `#t`},
+
+		// Rows are only parsed as they are needed
+		{`(head (csv-read "DIR/ragged.csv"))`, `{:a "1", :b "2"}`},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		src := inDir(tt.src)
+		got, err := evalSource(e, src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if String(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.src, String(got), tt.want)
+		}
+	}
+
+	for _, src := range []string{
+		`(len (csv-read "DIR/ragged.csv"))`,
+		`(csv-read "DIR/missing.csv")`,
+		`(csv-read 1)`,
+		`(csv-read "DIR/people.csv" :sep "ab")`,
+		`(csv-read "DIR/people.csv" :sep "")`,
+		`(csv-read "DIR/people.csv" :sep "\"")`,
+		`(csv-read "DIR/people.csv" :sep "\n")`,
+		`(csv-read "DIR/people.csv" :sep 1)`,
+		`(csv-read "DIR/people.csv" :seperator ";")`,
+		`(csv-read "DIR/people.csv" :header)`,
+	} {
+		e := newTestEvaluator(t)
+		if _, err := evalSource(e, inDir(src)); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
+
+func TestCSVReadFromPort(t *testing.T) {
+	e := newTestEvaluator(t)
+	e.SetInput(strings.NewReader("x,y\n1,2\n3,4\n"))
+	got, err := evalSource(e, `(csv-read (current-input-port) :numbers #t)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `({:x 1, :y 2} {:x 3, :y 4})`; String(got) != want {
+		t.Errorf("got %s, want %s", String(got), want)
+	}
+}
+
+func TestCSVWrite(t *testing.T) {
+	inDir := csvFiles(t, nil)
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`(csv-write "DIR/out.csv" '(("a" "b") [1 :c] (nil "x,y")))`, "a,b\n1,c\n,\"x,y\"\n"},
+		{`(csv-write "DIR/out.csv" [{:b 2 :a 1} {:a "q\"q"}])`, "a,b\n1,2\n\"q\"\"q\",\n"},
+		{`(csv-write "DIR/out.csv" [{:b 2 :a 1}] :header [:b])`, "b\n2\n"},
+		{`(csv-write "DIR/out.csv" [[1.5 2]] :header '("x" "y"))`, "x,y\n1.5,2\n"},
+		{`(csv-write "DIR/out.tsv" [{:a "1 2" :b 3}])`, "a\tb\n1 2\t3\n"},
+		{`(csv-write "DIR/out.csv" [[1 2]] :sep "|")`, "1|2\n"},
+		{`(csv-write "DIR/out.csv" '())`, ""},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		src := inDir(tt.src)
+		if _, err := evalSource(e, src); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		path := inDir("DIR/out.csv")
+		if strings.Contains(tt.src, ".tsv") {
+			path = inDir("DIR/out.tsv")
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: wrote %q, want %q", tt.src, got, tt.want)
+		}
+	}
+
+	for _, src := range []string{
+		`(csv-write "DIR/out.csv" [[1] {:a 1}])`,
+		`(csv-write "DIR/out.csv" [[1] 2])`,
+		`(csv-write "DIR/out.csv" 1)`,
+		`(csv-write 1 [])`,
+		`(csv-write "DIR/out.csv" [] :sep "\n")`,
+		`(csv-write "DIR/out.csv" [] :numbers #t)`,
+	} {
+		e := newTestEvaluator(t)
+		if _, err := evalSource(e, inDir(src)); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
+
+// Rows written with csv-write read back in as the same rows
+func TestCSVRoundTrip(t *testing.T) {
+	inDir := csvFiles(t, nil)
+	tests := []struct {
+		rows string
+		opts string
+	}{
+		{`({:id 1 :name "a, \"b\"" :note "line\nbreak"} {:id 2 :name "" :note "λ"})`, `:numbers #t`},
+		{`(["x" "y"] ["1" "2"])`, `:header #f`},
+		{`({:a "tab\there" :b "-"})`, ``},
+	}
+
+	for _, tt := range tests {
+		for _, name := range []string{"rows.csv", "rows.tsv"} {
+			e := newTestEvaluator(t)
+			src := inDir(`(define rows '` + tt.rows + `)
+				(csv-write "DIR/` + name + `" rows)
+				(csv-read "DIR/` + name + `" ` + tt.opts + `)`)
+			got, err := evalSource(e, src)
+			if err != nil {
+				t.Errorf("%s in %s: unexpected error: %v", tt.rows, name, err)
+				continue
+			}
+			want, _ := evalSource(e, `rows`)
+			if String(got) != String(want) {
+				t.Errorf("%s in %s: read back as %s", tt.rows, name, String(got))
+			}
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`(get {:a 1} :a)`, `1`},
+		{`(get {:a 1} :b)`, `nil`},
+		{`(get {:a 1} :b 0)`, `0`},
+		{`(get {:a nil} :a 0)`, `nil`},
+		{`(get {"k" [1]} "k")`, `[1]`},
+		{`(get #{:x} :x)`, `:x`},
+		{`(get #{:x} :y :none)`, `:none`},
+		{`(get [10 20] 1)`, `20`},
+		{`(get [10 20] 2 :out)`, `:out`},
+		{`(get [10 20] -1)`, `nil`},
+		{`(get [10 20] :a)`, `nil`},
+		{`(get nil :a 3)`, `3`},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		got, err := evalSource(e, tt.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if String(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.src, String(got), tt.want)
+		}
+	}
+
+	for _, src := range []string{`(get {:a 1})`, `(get {:a 1} :a 1 2)`, `(get "abc" 0)`, `(get {:a 1} [1])`} {
+		e := newTestEvaluator(t)
+		if _, err := evalSource(e, src); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package gigl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+	Documentation
+
+	doc and apropos work from what the evaluator knows about each name.
+	Special forms have a fixed usage string, builtins are described in
+	builtinDocs and defn and defmacro record the parameter lists and
+	docstrings of the procedures and macros that they define as metadata
+	(see meta.go).
+*/
+
+// The usage of each special form handled directly by eval
+var specialForms = map[SYMBOL]string{
+	"quote":            "(quote x)",
+	"quasiquote":       "(quasiquote x)",
+	"unquote":          "(unquote x)",
+	"unquote-splicing": "(unquote-splicing x)",
+	"if":               "(if test then [else])",
+	"cond":             "(cond (test body ...) ... (:else body ...))",
+	"and":              "(and x ...)",
+	"or":               "(or x ...)",
+	"when":             "(when test body ...)",
+	"unless":           "(unless test body ...)",
+	"while":            "(while test body ...)",
+	"case":             "(case key ((datum ...) body ...) ... (else body ...))",
+	"do":               "(do ((var init step) ...) (test result ...) body ...)",
+	"with-open-file":   "(with-open-file (port path [mode]) body ...)",
+	"doc":              "(doc sym)",
+	"the-environment":  "(the-environment)",
+	"set!":             "(set! sym x)",
+	"define":           "(define sym x)",
+	"lambda":           "(lambda (params ...) body)",
+	"λ":                "(λ (params ...) body)",
+	"defn":             "(defn name [docstring] [attr-map] (params ...) body)",
+	"defmacro":         "(defmacro name [docstring] [attr-map] (params ...) body)",
+	"let":              "(let ((sym x) ...) body)",
+	"begin":            "(begin body ...)",
+	"apply":            "(apply f args)",
+}
+
+// usage formats a call to name with the given parameters: (f x y), or
+// (f . args) for a procedure that takes any number of arguments
+func usage(name SYMBOL, params lispVal) string {
+	var names []string
+	switch params := params.(type) {
+	case *LispList:
+		for _, p := range params.toSlice() {
+			names = append(names, String(p))
+		}
+	case []lispVal:
+		for _, p := range params {
+			names = append(names, String(p))
+		}
+	case SYMBOL:
+		names = []string{".", string(params)}
+	}
+	return "(" + strings.Join(append([]string{string(name)}, names...), " ") + ")"
+}
+
+// docFor describes what a symbol refers to for (doc sym) and ,doc
+func (e *Evaluator) docFor(sym SYMBOL, env *environment) (string, error) {
+	if env == nil {
+		env = e.globalEnv
+	}
+	lines := []string{string(sym)}
+	var meta MAP
+
+	if form, ok := specialForms[sym]; ok {
+		lines = append(lines, "  "+form, "  Special form")
+	} else if _, ok := e.macroTable[sym]; ok {
+		meta = e.macroMeta[sym]
+		lines = append(lines, "  "+usage(sym, meta[KEYWORD("arglists")]), "  Macro")
+	} else if frame := env.find(sym); frame != nil {
+		meta = frame.meta[sym]
+		switch val := frame.vals[sym].(type) {
+		case func(...lispVal) (lispVal, error), *Procedure:
+			// (define f (with-meta ...)) only records where f was defined
+			if _, ok := meta[KEYWORD("arglists")]; !ok && meta[KEYWORD("doc")] == nil {
+				if vm := e.valueMeta(val); vm != nil {
+					meta = vm
+				}
+			}
+			kind := "  Procedure"
+			if _, ok := builtinDocs[sym]; ok && frame == e.globalEnv {
+				kind = "  Builtin procedure"
+			}
+			if params, ok := meta[KEYWORD("arglists")]; ok {
+				lines = append(lines, "  "+usage(sym, params), kind)
+			} else {
+				lines = append(lines, kind)
+			}
+		default:
+			lines = append(lines, "  "+String(val))
+		}
+	} else {
+		return "", fmt.Errorf("Unknown symbol: %v", sym)
+	}
+
+	if doc, ok := meta[KEYWORD("doc")].(string); ok {
+		lines = append(lines, "", "  "+strings.ReplaceAll(doc, "\n", "\n  "))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// allNames lists every name that can be used in env: bound symbols,
+// macros and special forms
+func (e *Evaluator) allNames(env *environment) []string {
+	seen := make(map[SYMBOL]bool)
+	for _, sym := range env.names() {
+		seen[sym] = true
+	}
+	for sym := range e.macroTable {
+		seen[sym] = true
+	}
+	for sym := range specialForms {
+		seen[sym] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for sym := range seen {
+		names = append(names, string(sym))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// find every name containing a substring: (apropos "str")
+func (e *Evaluator) apropos(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("apropos takes a single string or symbol")
+	}
+	var part string
+	switch x := lst[0].(type) {
+	case string:
+		part = x
+	case SYMBOL:
+		part = string(x)
+	default:
+		return nil, fmt.Errorf("apropos takes a single string or symbol")
+	}
+
+	matches := make([]lispVal, 0)
+	for _, name := range e.allNames(e.globalEnv) {
+		if strings.Contains(name, part) {
+			matches = append(matches, SYMBOL(name))
+		}
+	}
+	return List(matches...), nil
+}
@@ -0,0 +1,105 @@
+package gigl
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDoc(t *testing.T) {
+	defs := `
+		(defn twice "Double x.\nTwice over." (x) (* x 2))
+		(defn vary (x . rest) x)
+		(defmacro my-unless "The opposite of when." (c body) (list 'if c nil body))
+		(define answer 42)
+		(define g (with-meta (lambda (y) y) {:doc "From with-meta." :arglists (y)}))
+		(define h twice)`
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`(doc twice)`, "twice\n  (twice x)\n  Procedure\n\n  Double x.\n  Twice over.\n"},
+		{`(doc vary)`, "vary\n  (vary x . rest)\n  Procedure\n"},
+		{`(doc my-unless)`, "my-unless\n  (my-unless c body)\n  Macro\n\n  The opposite of when.\n"},
+		{`(doc answer)`, "answer\n  42\n"},
+		{`(doc g)`, "g\n  (g y)\n  Procedure\n\n  From with-meta.\n"},
+		{`(doc h)`, "h\n  (h x)\n  Procedure\n\n  Double x.\n  Twice over.\n"},
+		{`(doc car)`, "car\n  (car lst)\n  Builtin procedure\n\n  The first element of a list.\n"},
+		{`(doc if)`, "if\n  (if test then [else])\n  Special form\n"},
+		{`(let ((local "x")) (doc local))`, "local\n  \"x\"\n"},
+		{`(defn shadow (car) (doc car)) (shadow 1)`, "car\n  1\n"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		if _, err := evalSource(e, defs); err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		e.SetOutput(&out)
+		got, err := evalSource(e, tt.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if got != nil {
+			t.Errorf("%s: returned %s rather than nil", tt.src, String(got))
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: wrote %q, want %q", tt.src, out.String(), tt.want)
+		}
+	}
+
+	for _, src := range []string{`(doc nope)`, `(doc "car")`, `(doc car cdr)`, `(doc)`} {
+		e := newTestEvaluator(t)
+		if _, err := evalSource(e, src); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
+
+// Every builtin is documented and every documented builtin exists
+func TestBuiltinDocs(t *testing.T) {
+	e := newTestEvaluator(t)
+	for sym := range builtinDocs {
+		if _, ok := e.globalEnv.vals[sym]; !ok {
+			t.Errorf("%s is documented but isn't a builtin", sym)
+		}
+	}
+}
+
+func TestApropos(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`(apropos "string->")`, `(string->list string->number)`},
+		{`(apropos 'string->)`, `(string->list string->number)`},
+		{`(apropos "unles")`, `(my-unless unless)`},
+		{`(apropos "my-")`, `(my-unless my-value)`},
+		{`(apropos "quasi")`, `(quasiquote)`},
+		{`(apropos "no such name")`, `()`},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		if _, err := evalSource(e, `(define my-value 1) (defmacro my-unless (c body) (list 'if c nil body))`); err != nil {
+			t.Fatal(err)
+		}
+		got, err := evalSource(e, tt.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if String(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.src, String(got), tt.want)
+		}
+	}
+
+	for _, src := range []string{`(apropos)`, `(apropos 1)`, `(apropos "a" "b")`} {
+		e := newTestEvaluator(t)
+		if _, err := evalSource(e, src); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
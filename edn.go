@@ -0,0 +1,233 @@
+package gigl
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/sminez/gigl/edn"
+)
+
+/*
+	edn interchange
+
+	read-edn and write-edn convert between gigl values and edn text using
+	the strict edn package rather than the gigl reader, so nothing is ever
+	evaluated and only data (no procedures, regexes or ports) can be
+	written. Integers become floats on the way in; floats with no
+	fractional part are written out as integers.
+*/
+
+// ednToLisp converts a decoded edn value into the equivalent gigl value
+func ednToLisp(v interface{}) lispVal {
+	switch x := v.(type) {
+	case int64:
+		return float64(x)
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(x).Float64()
+		return f
+	case edn.Keyword:
+		return KEYWORD(x)
+	case edn.Symbol:
+		return SYMBOL(x)
+	case edn.Char:
+		return CHAR(x)
+	case edn.List:
+		vals := make([]lispVal, len(x))
+		for i, item := range x {
+			vals[i] = ednToLisp(item)
+		}
+		return List(vals...)
+	case []interface{}:
+		vals := make([]lispVal, len(x))
+		for i, item := range x {
+			vals[i] = ednToLisp(item)
+		}
+		return vals
+	case map[interface{}]interface{}:
+		m := make(MAP, len(x))
+		for k, item := range x {
+			m[ednToLisp(k)] = ednToLisp(item)
+		}
+		return m
+	case edn.Set:
+		s := make(SET, len(x))
+		for k := range x {
+			s[ednToLisp(k)] = true
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// lispToEDN converts a gigl value into something that edn.Marshal can write
+func lispToEDN(v lispVal) (interface{}, error) {
+	switch x := v.(type) {
+	case nil, bool, string, time.Time, edn.Tagged, edn.UUID:
+		return x, nil
+	case float64:
+		if x == math.Trunc(x) && math.Abs(x) < 1e15 {
+			return int64(x), nil
+		}
+		return x, nil
+	case KEYWORD:
+		return edn.Keyword(x), nil
+	case SYMBOL:
+		return edn.Symbol(x), nil
+	case CHAR:
+		return edn.Char(x), nil
+	case *LispList:
+		vals, err := lispSliceToEDN(x.toSlice())
+		return edn.List(vals), err
+	case *LazySeq:
+		items, err := x.toSlice()
+		if err != nil {
+			return nil, err
+		}
+		vals, err := lispSliceToEDN(items)
+		return edn.List(vals), err
+	case []lispVal:
+		return lispSliceToEDN(x)
+	case VECTOR:
+		return lispSliceToEDN(x)
+	case MAP:
+		m := make(map[interface{}]interface{}, len(x))
+		for k, item := range x {
+			ek, err := lispToEDN(k)
+			if err != nil {
+				return nil, err
+			}
+			if !edn.Hashable(ek) {
+				return nil, fmt.Errorf("Unable to use as an edn map key: %v", String(k))
+			}
+			if m[ek], err = lispToEDN(item); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case SET:
+		s := make(edn.Set, len(x))
+		for k := range x {
+			ek, err := lispToEDN(k)
+			if err != nil {
+				return nil, err
+			}
+			if !edn.Hashable(ek) {
+				return nil, fmt.Errorf("Unable to use as an edn set element: %v", String(k))
+			}
+			s[ek] = true
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("Unable to write as edn: %v", String(v))
+	}
+}
+
+func lispSliceToEDN(items []lispVal) ([]interface{}, error) {
+	vals := make([]interface{}, len(items))
+	for i, item := range items {
+		v, err := lispToEDN(item)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// ednDecoder makes a decoder with the tag handlers given by the :readers
+// option: :readers {my/tag (lambda (x) ...)}
+func (e *Evaluator) ednDecoder(r io.Reader, opt lispVal) (*edn.Decoder, error) {
+	d := edn.NewDecoder(r)
+	if opt == nil {
+		return d, nil
+	}
+	readers, ok := opt.(MAP)
+	if !ok {
+		return nil, fmt.Errorf("read-edn: :readers must be a map of tags to procedures")
+	}
+	for tag, proc := range readers {
+		name, ok := tag.(SYMBOL)
+		if !ok {
+			return nil, fmt.Errorf("read-edn: reader tags must be symbols: %v", String(tag))
+		}
+		if _, ok := asProcedure(proc); !ok {
+			// Map literals aren't evaluated so the reader is still a symbol
+			// or a lambda expression at this point
+			var err error
+			if proc, err = e.eval(proc, e.globalEnv); err != nil {
+				return nil, err
+			}
+		}
+		proc := proc
+		d.AddTagHandler(string(name), func(value interface{}) (interface{}, error) {
+			return e.apply(proc, []lispVal{ednToLisp(value)})
+		})
+	}
+	return d, nil
+}
+
+// read a value in edn: (read-edn [string-or-port] [:readers {tag f}])
+func (e *Evaluator) readEDN(lst ...lispVal) (lispVal, error) {
+	// Options come in pairs so an odd number of arguments means that the
+	// source was given
+	nargs := len(lst) % 2
+	opts, err := keywordArgs("read-edn", lst, nargs, "readers")
+	if err != nil {
+		return nil, err
+	}
+	lst = lst[:nargs]
+
+	var r io.Reader
+	src, isString := "", false
+	if len(lst) == 1 {
+		src, isString = lst[0].(string)
+	}
+	if isString {
+		r = strings.NewReader(src)
+	} else {
+		port, err := e.inputPortArg("read-edn", lst)
+		if err != nil {
+			return nil, err
+		}
+		r = port
+	}
+
+	d, err := e.ednDecoder(r, opts["readers"])
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		if err == io.EOF {
+			if isString {
+				return nil, fmt.Errorf("Nothing to read in: %v", src)
+			}
+			return nil, nil
+		}
+		return nil, err
+	}
+	return ednToLisp(v), nil
+}
+
+// write a value as edn: (write-edn x [port])
+func (e *Evaluator) writeEDN(lst ...lispVal) (lispVal, error) {
+	port, err := e.outputPortArg("write-edn", lst, 1)
+	if err != nil {
+		return nil, err
+	}
+	v, err := lispToEDN(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	b, err := edn.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	_, err = port.writer.Write(b)
+	return nil, err
+}
@@ -0,0 +1,675 @@
+package edn
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Unmarshal decodes the single edn value in data and stores it in the value
+// pointed to by v
+func Unmarshal(data []byte, v interface{}) error {
+	d := NewDecoder(bytes.NewReader(data))
+	if err := d.Decode(v); err != nil {
+		if err == io.EOF {
+			return d.errorf("unexpected end of input")
+		}
+		return err
+	}
+	if err := d.skipSpace(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return d.errorf("unexpected data after value")
+	}
+	return nil
+}
+
+// A Decoder reads edn values from an input stream. It only reads as much of
+// the input as it needs to so that the stream can be shared with other
+// readers if it is an io.RuneScanner (such as a *bufio.Reader).
+type Decoder struct {
+	r    io.RuneScanner
+	line int
+	col  int
+	prev [2]int // the position before the last rune read so that it can be unread
+	tags map[string]TagHandler
+}
+
+// NewDecoder returns a decoder that reads from r with handlers for the
+// #inst and #uuid tags
+func NewDecoder(r io.Reader) *Decoder {
+	rs, ok := r.(io.RuneScanner)
+	if !ok {
+		rs = bufio.NewReader(r)
+	}
+	return &Decoder{
+		r:    rs,
+		line: 1,
+		col:  1,
+		tags: map[string]TagHandler{"inst": decodeInst, "uuid": decodeUUID},
+	}
+}
+
+// AddTagHandler sets the handler used for values tagged with #tag
+func (d *Decoder) AddTagHandler(tag string, h TagHandler) {
+	d.tags[tag] = h
+}
+
+// Decode reads the next edn value and stores it in the value pointed to by
+// v. It returns io.EOF if there are no more values.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("edn: Decode needs a non-nil pointer, got %T", v)
+	}
+	val, err := d.readValue()
+	if err == errNoValue {
+		r, _ := d.readRune()
+		return d.errorf("unexpected `%c`", r)
+	}
+	if err != nil {
+		return err
+	}
+	return assign(rv.Elem(), val)
+}
+
+// errNoValue is returned by readValue when it finds a closing bracket
+var errNoValue = errors.New("no value")
+
+func (d *Decoder) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Msg: fmt.Sprintf(format, args...), Line: d.line, Col: d.col}
+}
+
+func (d *Decoder) readRune() (rune, error) {
+	r, _, err := d.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	d.prev = [2]int{d.line, d.col}
+	if r == '\n' {
+		d.line++
+		d.col = 1
+	} else {
+		d.col++
+	}
+	return r, nil
+}
+
+func (d *Decoder) unreadRune() {
+	d.r.UnreadRune()
+	d.line, d.col = d.prev[0], d.prev[1]
+}
+
+// mustReadRune reads a rune that has to be there
+func (d *Decoder) mustReadRune(context string) (rune, error) {
+	r, err := d.readRune()
+	if err == io.EOF {
+		return 0, d.errorf("unexpected end of input in %s", context)
+	}
+	return r, err
+}
+
+func isWhitespace(r rune) bool {
+	return r == ',' || unicode.IsSpace(r)
+}
+
+func isDelimiter(r rune) bool {
+	switch r {
+	case '(', ')', '[', ']', '{', '}', '"', ';':
+		return true
+	}
+	return isWhitespace(r)
+}
+
+// skipSpace skips whitespace and comments, returning io.EOF at the end of
+// the input
+func (d *Decoder) skipSpace() error {
+	for {
+		r, err := d.readRune()
+		if err != nil {
+			return err
+		}
+		switch {
+		case r == ';':
+			for r != '\n' {
+				if r, err = d.readRune(); err != nil {
+					return err
+				}
+			}
+		case !isWhitespace(r):
+			d.unreadRune()
+			return nil
+		}
+	}
+}
+
+// readValue reads the next value, returning errNoValue without consuming
+// anything if the next thing in the input is a closing bracket
+func (d *Decoder) readValue() (interface{}, error) {
+	for {
+		if err := d.skipSpace(); err != nil {
+			return nil, err
+		}
+		r, err := d.readRune()
+		if err != nil {
+			return nil, err
+		}
+
+		switch r {
+		case '(':
+			items, err := d.readSeq(')')
+			return List(items), err
+
+		case '[':
+			return d.readSeq(']')
+
+		case '{':
+			return d.readMap()
+
+		case ')', ']', '}':
+			d.unreadRune()
+			return nil, errNoValue
+
+		case '"':
+			return d.readString()
+
+		case '\\':
+			return d.readChar()
+
+		case '#':
+			next, err := d.mustReadRune("dispatch")
+			if err != nil {
+				return nil, err
+			}
+			switch next {
+			case '{':
+				return d.readSet()
+			case '_':
+				// Discard the next value
+				if _, err := d.readRequired("#_"); err != nil {
+					return nil, err
+				}
+				continue
+			default:
+				d.unreadRune()
+				return d.readTagged()
+			}
+
+		default:
+			d.unreadRune()
+			return d.readAtom()
+		}
+	}
+}
+
+// readRequired reads a value that has to be there
+func (d *Decoder) readRequired(after string) (interface{}, error) {
+	v, err := d.readValue()
+	switch err {
+	case io.EOF:
+		return nil, d.errorf("unexpected end of input after %s", after)
+	case errNoValue:
+		return nil, d.errorf("missing value after %s", after)
+	}
+	return v, err
+}
+
+// readSeq reads values up to a closing bracket
+func (d *Decoder) readSeq(close rune) ([]interface{}, error) {
+	items := make([]interface{}, 0)
+	for {
+		v, err := d.readValue()
+		switch err {
+		case nil:
+			items = append(items, v)
+		case io.EOF:
+			return nil, d.errorf("unexpected end of input, expected `%c`", close)
+		case errNoValue:
+			r, _ := d.readRune()
+			if r != close {
+				// Report the position of the bracket rather than after it
+				d.unreadRune()
+				return nil, d.errorf("unexpected `%c`, expected `%c`", r, close)
+			}
+			return items, nil
+		default:
+			return nil, err
+		}
+	}
+}
+
+func (d *Decoder) readMap() (interface{}, error) {
+	items, err := d.readSeq('}')
+	if err != nil {
+		return nil, err
+	}
+	if len(items)%2 != 0 {
+		return nil, d.errorf("map literal must contain an even number of forms")
+	}
+	m := make(map[interface{}]interface{}, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		if err := d.checkKey(items[i]); err != nil {
+			return nil, err
+		}
+		if _, dup := m[items[i]]; dup {
+			return nil, d.errorf("duplicate map key %s", show(items[i]))
+		}
+		m[items[i]] = items[i+1]
+	}
+	return m, nil
+}
+
+func (d *Decoder) readSet() (interface{}, error) {
+	items, err := d.readSeq('}')
+	if err != nil {
+		return nil, err
+	}
+	s := make(Set, len(items))
+	for _, v := range items {
+		if err := d.checkKey(v); err != nil {
+			return nil, err
+		}
+		if s[v] {
+			return nil, d.errorf("duplicate set element %s", show(v))
+		}
+		s[v] = true
+	}
+	return s, nil
+}
+
+// checkKey makes sure that a value can be used as a Go map key
+func (d *Decoder) checkKey(v interface{}) error {
+	if !Hashable(v) {
+		return d.errorf("unsupported %s as a map key or set element", describe(v))
+	}
+	return nil
+}
+
+// readToken reads up to the next delimiter
+func (d *Decoder) readToken() (string, error) {
+	var b strings.Builder
+	for {
+		r, err := d.readRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if isDelimiter(r) {
+			d.unreadRune()
+			break
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), nil
+}
+
+func (d *Decoder) readTagged() (interface{}, error) {
+	tag, err := d.readToken()
+	if err != nil {
+		return nil, err
+	}
+	if tag == "" || !unicode.IsLetter([]rune(tag)[0]) || !validSymbol(tag) {
+		return nil, d.errorf("invalid tag #%s", tag)
+	}
+	v, err := d.readRequired("#" + tag)
+	if err != nil {
+		return nil, err
+	}
+	if h, ok := d.tags[tag]; ok {
+		return h(v)
+	}
+	return Tagged{Tag: tag, Value: v}, nil
+}
+
+var (
+	intPattern   = regexp.MustCompile(`^[+-]?(?:0|[1-9][0-9]*)N?$`)
+	floatPattern = regexp.MustCompile(`^[+-]?(?:0|[1-9][0-9]*)(?:\.[0-9]*)?(?:[eE][+-]?[0-9]+)?M?$`)
+)
+
+func (d *Decoder) readAtom() (interface{}, error) {
+	tok, err := d.readToken()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case tok == "nil":
+		return nil, nil
+	case tok == "true":
+		return true, nil
+	case tok == "false":
+		return false, nil
+
+	case intPattern.MatchString(tok):
+		digits := strings.TrimSuffix(tok, "N")
+		if i, err := strconv.ParseInt(digits, 10, 64); err == nil {
+			return i, nil
+		}
+		n, _ := new(big.Int).SetString(strings.TrimPrefix(digits, "+"), 10)
+		return n, nil
+
+	case floatPattern.MatchString(tok):
+		f, err := strconv.ParseFloat(strings.TrimSuffix(tok, "M"), 64)
+		if err != nil {
+			return nil, d.errorf("invalid number %s", tok)
+		}
+		return f, nil
+
+	case strings.HasPrefix(tok, ":"):
+		if !validSymbol(tok[1:]) || strings.HasPrefix(tok, "::") {
+			return nil, d.errorf("invalid keyword %s", tok)
+		}
+		return Keyword(tok[1:]), nil
+
+	case validSymbol(tok):
+		return Symbol(tok), nil
+
+	default:
+		return nil, d.errorf("invalid token %s", tok)
+	}
+}
+
+// validSymbol checks the rules for symbols from the edn spec
+func validSymbol(s string) bool {
+	if s == "/" {
+		return true
+	}
+	if parts := strings.Split(s, "/"); len(parts) == 2 {
+		return validSymbolName(parts[0]) && validSymbolName(parts[1])
+	}
+	return validSymbolName(s)
+}
+
+func validSymbolName(s string) bool {
+	runes := []rune(s)
+	if len(runes) == 0 || unicode.IsDigit(runes[0]) || runes[0] == ':' || runes[0] == '#' {
+		return false
+	}
+	if strings.ContainsRune("+-.", runes[0]) && len(runes) > 1 && unicode.IsDigit(runes[1]) {
+		return false
+	}
+	for _, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(".*+!-_?$%&=<>:#'", r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Decoder) readString() (interface{}, error) {
+	var b strings.Builder
+	for {
+		r, err := d.mustReadRune("string")
+		if err != nil {
+			return nil, err
+		}
+		switch r {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			esc, err := d.mustReadRune("string")
+			if err != nil {
+				return nil, err
+			}
+			switch esc {
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'n':
+				b.WriteByte('\n')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case '\\', '"':
+				b.WriteRune(esc)
+			case 'u':
+				r, err := d.readHex()
+				if err != nil {
+					return nil, err
+				}
+				b.WriteRune(r)
+			default:
+				return nil, d.errorf("unknown escape sequence \\%c", esc)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// readHex reads the four hex digits of a \uXXXX escape
+func (d *Decoder) readHex() (rune, error) {
+	var digits [4]rune
+	for i := range digits {
+		r, err := d.mustReadRune("unicode escape")
+		if err != nil {
+			return 0, err
+		}
+		digits[i] = r
+	}
+	n, err := strconv.ParseUint(string(digits[:]), 16, 32)
+	if err != nil {
+		return 0, d.errorf("invalid unicode escape \\u%s", string(digits[:]))
+	}
+	return rune(n), nil
+}
+
+var charNames = map[string]Char{
+	"newline": '\n',
+	"return":  '\r',
+	"space":   ' ',
+	"tab":     '\t',
+}
+
+func (d *Decoder) readChar() (interface{}, error) {
+	first, err := d.mustReadRune("character")
+	if err != nil {
+		return nil, err
+	}
+	rest, err := d.readToken()
+	if err != nil {
+		return nil, err
+	}
+	name := string(first) + rest
+
+	if rest == "" {
+		return Char(first), nil
+	}
+	if c, ok := charNames[name]; ok {
+		return c, nil
+	}
+	if first == 'u' && len(rest) == 4 {
+		if n, err := strconv.ParseUint(rest, 16, 32); err == nil {
+			return Char(n), nil
+		}
+	}
+	return nil, d.errorf("invalid character \\%s", name)
+}
+
+// assign stores a decoded value in dst, converting it to the type of dst
+func assign(dst reflect.Value, src interface{}) error {
+	if dst.CanAddr() {
+		if u, ok := dst.Addr().Interface().(Unmarshaler); ok {
+			return u.UnmarshalEDN(src)
+		}
+	}
+
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return assign(dst.Elem(), src)
+
+	case reflect.Interface:
+		if dst.NumMethod() == 0 {
+			dst.Set(reflect.ValueOf(src))
+			return nil
+		}
+	}
+
+	sv := reflect.ValueOf(src)
+	if sv.Type().AssignableTo(dst.Type()) {
+		dst.Set(sv)
+		return nil
+	}
+	mismatch := &UnmarshalTypeError{Value: describe(src), Type: dst.Type()}
+
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := src.(int64)
+		if !ok || dst.OverflowInt(i) {
+			return mismatch
+		}
+		dst.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, ok := src.(int64)
+		if !ok || i < 0 || dst.OverflowUint(uint64(i)) {
+			return mismatch
+		}
+		dst.SetUint(uint64(i))
+
+	case reflect.Float32, reflect.Float64:
+		switch n := src.(type) {
+		case float64:
+			dst.SetFloat(n)
+		case int64:
+			dst.SetFloat(float64(n))
+		default:
+			return mismatch
+		}
+
+	case reflect.String:
+		// Keywords and symbols are often used for enumerations
+		switch s := src.(type) {
+		case string:
+			dst.SetString(s)
+		case Keyword:
+			dst.SetString(string(s))
+		case Symbol:
+			dst.SetString(string(s))
+		default:
+			return mismatch
+		}
+
+	case reflect.Slice, reflect.Array:
+		var items []interface{}
+		switch s := src.(type) {
+		case []interface{}:
+			items = s
+		case List:
+			items = s
+		default:
+			return mismatch
+		}
+		if dst.Kind() == reflect.Slice {
+			dst.Set(reflect.MakeSlice(dst.Type(), len(items), len(items)))
+		} else if dst.Len() != len(items) {
+			return mismatch
+		}
+		for i, item := range items {
+			if err := assign(dst.Index(i), item); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		return assignMap(dst, src, mismatch)
+
+	case reflect.Struct:
+		m, ok := src.(map[interface{}]interface{})
+		if !ok {
+			return mismatch
+		}
+		return assignStruct(dst, m)
+
+	default:
+		return mismatch
+	}
+	return nil
+}
+
+func assignMap(dst reflect.Value, src interface{}, mismatch error) error {
+	t := dst.Type()
+	dst.Set(reflect.MakeMap(t))
+	switch s := src.(type) {
+	case map[interface{}]interface{}:
+		for k, v := range s {
+			key := reflect.New(t.Key()).Elem()
+			if err := assign(key, k); err != nil {
+				return err
+			}
+			val := reflect.New(t.Elem()).Elem()
+			if err := assign(val, v); err != nil {
+				return err
+			}
+			dst.SetMapIndex(key, val)
+		}
+
+	case Set:
+		// Sets can be stored as a map to bool
+		if t.Elem().Kind() != reflect.Bool {
+			return mismatch
+		}
+		for k := range s {
+			key := reflect.New(t.Key()).Elem()
+			if err := assign(key, k); err != nil {
+				return err
+			}
+			dst.SetMapIndex(key, reflect.ValueOf(true).Convert(t.Elem()))
+		}
+
+	default:
+		return mismatch
+	}
+	return nil
+}
+
+// assignStruct sets the fields of a struct from the entries of a map.
+// Entries that don't match a field are ignored.
+func assignStruct(dst reflect.Value, m map[interface{}]interface{}) error {
+	t := dst.Type()
+	for k, v := range m {
+		var name string
+		switch key := k.(type) {
+		case Keyword:
+			name = string(key)
+		case Symbol:
+			name = string(key)
+		case string:
+			name = key
+		default:
+			continue
+		}
+
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if key, _ := fieldKey(f); key != "" && (key == name || strings.EqualFold(f.Name, name)) {
+				if err := assign(dst.Field(i), v); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
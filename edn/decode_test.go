@@ -0,0 +1,55 @@
+package edn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnhashableKeys(t *testing.T) {
+	for _, src := range []string{
+		"{[1] 1}",
+		"{#foo [1] 1}",
+		"{#foo #bar (1 2) 1}",
+		"#{#foo (1 2)}",
+		"#{{:a 1}}",
+	} {
+		var v interface{}
+		err := Unmarshal([]byte(src), &v)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: expected a syntax error, got %v", src, err)
+		}
+	}
+
+	for _, src := range []string{"{#foo 1 1}", "#{#foo :a #foo :b}", "{nil 1}"} {
+		var v interface{}
+		if err := Unmarshal([]byte(src), &v); err != nil {
+			t.Errorf("%s: unexpected error: %v", src, err)
+		}
+	}
+}
+
+func TestHashable(t *testing.T) {
+	tests := []struct {
+		val  interface{}
+		want bool
+	}{
+		{nil, true},
+		{int64(1), true},
+		{Keyword("a"), true},
+		{Tagged{"foo", "x"}, true},
+		{Tagged{"foo", nil}, true},
+		{Tagged{"foo", List{1}}, false},
+		{Tagged{"foo", Tagged{"bar", []interface{}{}}}, false},
+		{[]interface{}{}, false},
+		{Set{}, false},
+		{[2]interface{}{1, "a"}, true},
+		{[2]interface{}{1, List{}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := Hashable(tt.val); got != tt.want {
+			t.Errorf("Hashable(%#v) = %v, want %v", tt.val, got, tt.want)
+		}
+	}
+}
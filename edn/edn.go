@@ -0,0 +1,268 @@
+// Package edn reads and writes extensible data notation (edn), the data
+// format that gigl's reader syntax is based on.
+//
+// Decoding into an interface{} produces the following Go values:
+//
+//	nil, true, false      nil, bool
+//	integers              int64, or *big.Int if they don't fit
+//	floats                float64
+//	strings               string
+//	characters            Char
+//	symbols, keywords     Symbol, Keyword
+//	lists                 List
+//	vectors               []interface{}
+//	maps                  map[interface{}]interface{}
+//	sets                  Set
+//	#inst, #uuid          time.Time, UUID
+//	other tagged values   Tagged, unless the Decoder has a handler for the tag
+//
+// Unmarshal also decodes into structs, maps, slices and basic Go types in
+// the same way as encoding/json. Struct fields are matched against map
+// keys using an `edn:"name"` field tag if there is one and the field name
+// in kebab-case (MaxConns is :max-conns) otherwise.
+package edn
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Keyword is an edn keyword without its leading colon
+type Keyword string
+
+// Symbol is an edn symbol
+type Symbol string
+
+// Char is an edn character literal
+type Char rune
+
+// List is an edn list. Vectors decode to []interface{}.
+type List []interface{}
+
+// Set is an edn set
+type Set map[interface{}]bool
+
+// Tagged is a tagged value that has no handler
+type Tagged struct {
+	Tag   string
+	Value interface{}
+}
+
+func (t Tagged) String() string {
+	b, err := Marshal(t)
+	if err != nil {
+		return fmt.Sprintf("#%s %v", t.Tag, t.Value)
+	}
+	return string(b)
+}
+
+// Hashable reports whether v can be used as a map key or set element.
+// Unlike reflect's Comparable it looks inside interfaces, so a Tagged
+// value is only hashable if the value that it wraps is.
+func Hashable(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	return hashable(reflect.ValueOf(v))
+}
+
+func hashable(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface:
+		return v.IsNil() || hashable(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !hashable(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !hashable(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return v.Type().Comparable()
+	}
+}
+
+// UUID is the value of a #uuid tag
+type UUID [16]byte
+
+// ParseUUID parses the canonical 8-4-4-4-12 hex form of a UUID
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("edn: invalid uuid %q", s)
+	}
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil {
+		return u, fmt.Errorf("edn: invalid uuid %q", s)
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+func (u UUID) String() string {
+	h := hex.EncodeToString(u[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// Marshaler is implemented by types that can write themselves as edn
+type Marshaler interface {
+	MarshalEDN() ([]byte, error)
+}
+
+// Unmarshaler is implemented by types that can set themselves from a
+// decoded edn value
+type Unmarshaler interface {
+	UnmarshalEDN(value interface{}) error
+}
+
+// A TagHandler converts the value following a tag, such as the string in
+// #inst "1985-04-12T23:20:50.52Z", into a Go value
+type TagHandler func(value interface{}) (interface{}, error)
+
+// The layouts accepted by #inst, most precise first
+var instLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+func decodeInst(value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("edn: #inst expects a string, got %s", describe(value))
+	}
+	for _, layout := range instLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("edn: invalid #inst %q", s)
+}
+
+func decodeUUID(value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("edn: #uuid expects a string, got %s", describe(value))
+	}
+	return ParseUUID(s)
+}
+
+// SyntaxError is a problem with the edn text being decoded
+type SyntaxError struct {
+	Msg  string
+	Line int
+	Col  int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("edn: %s at %d:%d", e.Msg, e.Line, e.Col)
+}
+
+// UnmarshalTypeError is returned when a value can't be stored in the Go
+// value that was passed to Unmarshal
+type UnmarshalTypeError struct {
+	Value string
+	Type  reflect.Type
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	return fmt.Sprintf("edn: cannot unmarshal %s into Go value of type %v", e.Value, e.Type)
+}
+
+// describe names the kind of edn value that v came from for error messages
+func describe(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case Char:
+		return "character"
+	case Keyword:
+		return "keyword"
+	case Symbol:
+		return "symbol"
+	case List:
+		return "list"
+	case []interface{}:
+		return "vector"
+	case map[interface{}]interface{}:
+		return "map"
+	case Set:
+		return "set"
+	case Tagged:
+		return "tagged value"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// show formats a value as edn for error messages
+func show(v interface{}) string {
+	b, err := Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// kebabCase converts a Go field name to the name of an edn key:
+// MaxConns becomes max-conns and HTTPPort becomes http-port
+func kebabCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		lower := strings.ToLower(string(r))
+		if i > 0 && lower != string(r) {
+			prevLower := strings.ToLower(string(runes[i-1])) == string(runes[i-1])
+			nextLower := i+1 < len(runes) && strings.ToLower(string(runes[i+1])) == string(runes[i+1])
+			if prevLower || nextLower {
+				b.WriteByte('-')
+			}
+		}
+		b.WriteString(lower)
+	}
+	return b.String()
+}
+
+// fieldKey returns the key used for a struct field and whether empty values
+// are left out, or "" if the field is skipped
+func fieldKey(f reflect.StructField) (name string, omitEmpty bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	tag := f.Tag.Get("edn")
+	if tag == "-" {
+		return "", false
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = kebabCase(f.Name)
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty
+}
@@ -0,0 +1,277 @@
+package edn
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnmarshalValues(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{"nil", nil},
+		{"true", true},
+		{"42", int64(42)},
+		{"-7", int64(-7)},
+		{"123456789012345678901234567890", huge},
+		{"1.5", 1.5},
+		{"1e3", 1000.0},
+		{`"a\n\"b\""`, "a\n\"b\""},
+		{`\a`, Char('a')},
+		{`\newline`, Char('\n')},
+		{":k", Keyword("k")},
+		{":ns/k", Keyword("ns/k")},
+		{"sym", Symbol("sym")},
+		{"(1 :a)", List{int64(1), Keyword("a")}},
+		{"[1 [2]]", []interface{}{int64(1), []interface{}{int64(2)}}},
+		{"{:a 1, \"b\" nil}", map[interface{}]interface{}{Keyword("a"): int64(1), "b": nil}},
+		{"#{1 2}", Set{int64(1): true, int64(2): true}},
+		{"[1 #_ 2 3] ; comment", []interface{}{int64(1), int64(3)}},
+		{"#my/tag [1]", Tagged{"my/tag", []interface{}{int64(1)}}},
+	}
+
+	for _, tt := range tests {
+		var got interface{}
+		if err := Unmarshal([]byte(tt.src), &got); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.src, got, tt.want)
+		}
+	}
+
+	for _, src := range []string{"", "(1", "]", "1 2", `"open`, ":", "#{1 1}", "{:a}", "#1 x"} {
+		var got interface{}
+		var syntaxErr *SyntaxError
+		if err := Unmarshal([]byte(src), &got); !errors.As(err, &syntaxErr) {
+			t.Errorf("%q: expected a syntax error, got %v", src, err)
+		}
+	}
+}
+
+type server struct {
+	Name     string
+	MaxConns int
+	Tags     []string `edn:"labels"`
+	Weight   float64  `edn:",omitempty"`
+	Secret   string   `edn:"-"`
+	Enabled  bool
+	private  int
+}
+
+func TestUnmarshalStruct(t *testing.T) {
+	src := `{:name "web" :max-conns 10 :labels [:a b "c"] :enabled true :unknown 1 :secret "x"}`
+	var got server
+	if err := Unmarshal([]byte(src), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := server{Name: "web", MaxConns: 10, Tags: []string{"a", "b", "c"}, Enabled: true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	var m map[string]int
+	if err := Unmarshal([]byte(`{"a" 1 "b" 2}`), &m); err != nil || m["a"] != 1 || m["b"] != 2 {
+		t.Errorf("got %v, %v", m, err)
+	}
+	var set map[int64]bool
+	if err := Unmarshal([]byte(`#{1 2}`), &set); err != nil || !set[1] || !set[2] {
+		t.Errorf("got %v, %v", set, err)
+	}
+
+	for _, tt := range []struct {
+		src string
+		dst interface{}
+	}{
+		{`"a"`, new(int)},
+		{`300`, new(int8)},
+		{`-1`, new(uint)},
+		{`1.5`, new(int)},
+		{`{:max-conns "lots"}`, new(server)},
+		{`[1 2 3]`, new([2]int)},
+	} {
+		var typeErr *UnmarshalTypeError
+		if err := Unmarshal([]byte(tt.src), tt.dst); !errors.As(err, &typeErr) {
+			t.Errorf("%s into %T: expected a type error, got %v", tt.src, tt.dst, err)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	u, err := ParseUUID("f81d4fae-7dec-11d0-a765-00a0c91e6bf6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		val  interface{}
+		want string
+	}{
+		{nil, "nil"},
+		{true, "true"},
+		{42, "42"},
+		{2.0, "2.0"},
+		{1.5e-7, "1.5e-07"},
+		{"a\"b\n", `"a\"b\n"`},
+		{Char('x'), `\x`},
+		{Char(' '), `\space`},
+		{Keyword("k"), ":k"},
+		{Symbol("s"), "s"},
+		{List{1, "a"}, `(1 "a")`},
+		{[]int{1, 2}, "[1 2]"},
+		{[]int(nil), "nil"},
+		{map[string]int{"b": 2, "a": 1}, `{"a" 1, "b" 2}`},
+		{Set{Keyword("b"): true, Keyword("a"): true}, "#{:a :b}"},
+		{big.NewInt(7), "7N"},
+		{Tagged{"my/tag", []interface{}{1}}, "#my/tag [1]"},
+		{time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), `#inst "2020-01-02T03:04:05Z"`},
+		{u, `#uuid "f81d4fae-7dec-11d0-a765-00a0c91e6bf6"`},
+		{server{Name: "web", MaxConns: 3}, `{:name "web", :max-conns 3, :labels nil, :enabled false}`},
+	}
+
+	for _, tt := range tests {
+		got, err := Marshal(tt.val)
+		if err != nil {
+			t.Errorf("%#v: unexpected error: %v", tt.val, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%#v: got %s, want %s", tt.val, got, tt.want)
+		}
+	}
+
+	for _, val := range []interface{}{make(chan int), func() {}, []interface{}{complex(1, 2)}} {
+		var unsupported *UnsupportedValueError
+		if _, err := Marshal(val); !errors.As(err, &unsupported) {
+			t.Errorf("%T: expected an unsupported value error, got %v", val, err)
+		}
+	}
+}
+
+// Anything that Marshal writes reads back in as the same value
+func TestMarshalRoundTrip(t *testing.T) {
+	vals := []interface{}{
+		nil, false, int64(-3), 0.25, "λ \"quoted\"\t", Char('λ'), Char('\n'),
+		Keyword("a/b"), Symbol("+"),
+		List{int64(1), List{}}, []interface{}{"x", nil},
+		map[interface{}]interface{}{Keyword("a"): []interface{}{int64(1)}, int64(2): Set{"s": true}},
+		Tagged{"x", Keyword("y")},
+		time.Date(2001, 2, 3, 4, 5, 6, 7, time.UTC),
+	}
+
+	for _, val := range vals {
+		b, err := Marshal(val)
+		if err != nil {
+			t.Errorf("%#v: unexpected error: %v", val, err)
+			continue
+		}
+		var back interface{}
+		if err := Unmarshal(b, &back); err != nil {
+			t.Errorf("%s doesn't read back in: %v", b, err)
+			continue
+		}
+		if tm, ok := val.(time.Time); ok {
+			if !tm.Equal(back.(time.Time)) {
+				t.Errorf("%s read back as %v", b, back)
+			}
+		} else if !reflect.DeepEqual(back, val) {
+			t.Errorf("%s read back as %#v, want %#v", b, back, val)
+		}
+	}
+}
+
+func TestBuiltinTags(t *testing.T) {
+	instTests := []struct {
+		src  string
+		want time.Time
+	}{
+		{`#inst "1985-04-12T23:20:50.52Z"`, time.Date(1985, 4, 12, 23, 20, 50, 520000000, time.UTC)},
+		{`#inst "1985-04-12T19:20:50-04:00"`, time.Date(1985, 4, 12, 23, 20, 50, 0, time.UTC)},
+		{`#inst "1985-04-12T23:20"`, time.Date(1985, 4, 12, 23, 20, 0, 0, time.UTC)},
+		{`#inst "1985-04-12"`, time.Date(1985, 4, 12, 0, 0, 0, 0, time.UTC)},
+		{`#inst "1985"`, time.Date(1985, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range instTests {
+		var got interface{}
+		if err := Unmarshal([]byte(tt.src), &got); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if tm, ok := got.(time.Time); !ok || !tm.Equal(tt.want) {
+			t.Errorf("%s: got %#v, want %v", tt.src, got, tt.want)
+		}
+	}
+
+	var u interface{}
+	src := `#uuid "F81D4FAE-7DEC-11D0-A765-00A0C91E6BF6"`
+	if err := Unmarshal([]byte(src), &u); err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := u.(UUID); !ok || id.String() != "f81d4fae-7dec-11d0-a765-00a0c91e6bf6" {
+		t.Errorf("%s: got %#v", src, u)
+	}
+
+	for _, src := range []string{
+		`#inst "yesterday"`, `#inst 1985`, `#inst "1985-13-01"`,
+		`#uuid "f81d4fae"`, `#uuid "f81d4fae-7dec-11d0-a765-00a0c91e6bfz"`, `#uuid :id`,
+	} {
+		var got interface{}
+		if err := Unmarshal([]byte(src), &got); err == nil {
+			t.Errorf("%s: expected an error, got %#v", src, got)
+		}
+	}
+}
+
+func TestTagHandlers(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`#point [1 2] #upper "abc" #inst "x" #other 1`))
+	d.AddTagHandler("point", func(v interface{}) (interface{}, error) {
+		xy, ok := v.([]interface{})
+		if !ok || len(xy) != 2 {
+			return nil, fmt.Errorf("bad point %v", v)
+		}
+		return [2]interface{}{xy[0], xy[1]}, nil
+	})
+	d.AddTagHandler("upper", func(v interface{}) (interface{}, error) {
+		return strings.ToUpper(v.(string)), nil
+	})
+	// Handlers replace the built in ones
+	d.AddTagHandler("inst", func(v interface{}) (interface{}, error) {
+		return Keyword("inst-" + v.(string)), nil
+	})
+
+	want := []interface{}{
+		[2]interface{}{int64(1), int64(2)},
+		"ABC",
+		Keyword("inst-x"),
+		Tagged{"other", int64(1)},
+	}
+	for _, w := range want {
+		var got interface{}
+		if err := d.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("got %#v, want %#v", got, w)
+		}
+	}
+	var end interface{}
+	if err := d.Decode(&end); err == nil || err.Error() != "EOF" {
+		t.Errorf("expected EOF, got %v", err)
+	}
+
+	d = NewDecoder(strings.NewReader(`#point 1`))
+	d.AddTagHandler("point", func(v interface{}) (interface{}, error) {
+		return nil, errors.New("bad point")
+	})
+	var got interface{}
+	if err := d.Decode(&got); err == nil || err.Error() != "bad point" {
+		t.Errorf("expected the handler's error, got %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package edn
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Marshal returns the edn encoding of v. Maps and sets are written with
+// their keys sorted so that the output is deterministic, and structs are
+// written as maps with keyword keys.
+func Marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := encode(&b, v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// UnsupportedValueError is returned when a value has no edn representation
+type UnsupportedValueError struct {
+	Value interface{}
+}
+
+func (e *UnsupportedValueError) Error() string {
+	return fmt.Sprintf("edn: unsupported value: %v (%T)", e.Value, e.Value)
+}
+
+func encode(b *bytes.Buffer, v interface{}) error {
+	switch x := v.(type) {
+	case nil:
+		b.WriteString("nil")
+		return nil
+
+	case Marshaler:
+		out, err := x.MarshalEDN()
+		if err != nil {
+			return err
+		}
+		b.Write(out)
+		return nil
+
+	case time.Time:
+		b.WriteString("#inst ")
+		writeString(b, x.Format(time.RFC3339Nano))
+		return nil
+
+	case UUID:
+		b.WriteString("#uuid ")
+		writeString(b, x.String())
+		return nil
+
+	case Tagged:
+		b.WriteString("#" + x.Tag + " ")
+		return encode(b, x.Value)
+
+	case Keyword:
+		b.WriteString(":" + string(x))
+		return nil
+
+	case Symbol:
+		b.WriteString(string(x))
+		return nil
+
+	case Char:
+		writeChar(b, x)
+		return nil
+
+	case List:
+		return encodeSeq(b, "(", ")", x)
+
+	case Set:
+		keys := make([]interface{}, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sorted, err := encodeSorted(keys)
+		if err != nil {
+			return err
+		}
+		b.WriteString("#{" + strings.Join(sorted, " ") + "}")
+		return nil
+
+	case *big.Int:
+		b.WriteString(x.String() + "N")
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		b.WriteString(strconv.FormatBool(rv.Bool()))
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteString(strconv.FormatInt(rv.Int(), 10))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		b.WriteString(strconv.FormatUint(rv.Uint(), 10))
+
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return &UnsupportedValueError{v}
+		}
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			// Make sure that it reads back in as a float
+			s += ".0"
+		}
+		b.WriteString(s)
+
+	case reflect.String:
+		writeString(b, rv.String())
+
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			b.WriteString("nil")
+			return nil
+		}
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		return encodeSeq(b, "[", "]", items)
+
+	case reflect.Map:
+		if rv.IsNil() {
+			b.WriteString("nil")
+			return nil
+		}
+		entries := make([]string, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k, err := Marshal(iter.Key().Interface())
+			if err != nil {
+				return err
+			}
+			val, err := Marshal(iter.Value().Interface())
+			if err != nil {
+				return err
+			}
+			entries = append(entries, string(k)+" "+string(val))
+		}
+		sort.Strings(entries)
+		b.WriteString("{" + strings.Join(entries, ", ") + "}")
+
+	case reflect.Struct:
+		return encodeStruct(b, rv)
+
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			b.WriteString("nil")
+			return nil
+		}
+		return encode(b, rv.Elem().Interface())
+
+	default:
+		return &UnsupportedValueError{v}
+	}
+	return nil
+}
+
+func encodeSeq(b *bytes.Buffer, open, close string, items []interface{}) error {
+	b.WriteString(open)
+	for i, item := range items {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if err := encode(b, item); err != nil {
+			return err
+		}
+	}
+	b.WriteString(close)
+	return nil
+}
+
+// encodeSorted encodes each value and sorts the results
+func encodeSorted(vals []interface{}) ([]string, error) {
+	out := make([]string, len(vals))
+	for i, v := range vals {
+		enc, err := Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = string(enc)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// encodeStruct writes the exported fields of a struct as a map in field
+// order
+func encodeStruct(b *bytes.Buffer, rv reflect.Value) error {
+	t := rv.Type()
+	b.WriteString("{")
+	first := true
+	for i := 0; i < t.NumField(); i++ {
+		name, omitEmpty := fieldKey(t.Field(i))
+		if name == "" || (omitEmpty && rv.Field(i).IsZero()) {
+			continue
+		}
+		if !first {
+			b.WriteString(", ")
+		}
+		first = false
+		b.WriteString(":" + name + " ")
+		if err := encode(b, rv.Field(i).Interface()); err != nil {
+			return err
+		}
+	}
+	b.WriteString("}")
+	return nil
+}
+
+func writeString(b *bytes.Buffer, s string) {
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+func writeChar(b *bytes.Buffer, c Char) {
+	for name, named := range charNames {
+		if c == named {
+			b.WriteString(`\` + name)
+			return
+		}
+	}
+	if !unicode.IsPrint(rune(c)) && c <= 0xffff {
+		fmt.Fprintf(b, `\u%04x`, rune(c))
+		return
+	}
+	b.WriteString(`\` + string(rune(c)))
+}
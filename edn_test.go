@@ -0,0 +1,85 @@
+package gigl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEDNKeys(t *testing.T) {
+	for _, src := range []string{
+		`(read-edn "{#foo [1] 1}")`,
+		`(read-edn "#{#foo (1 2)}")`,
+		`(write-edn {(1 2) 3})`,
+		`(write-edn #{(1 2)})`,
+	} {
+		e := newTestEvaluator(t)
+		if _, err := evalSource(e, src); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+
+	e := newTestEvaluator(t)
+	var out strings.Builder
+	e.SetOutput(&out)
+	if _, err := evalSource(e, `(write-edn {:a #{1 2} "b" "c"})`); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"b" "c", :a #{1 2}}`; out.String() != want {
+		t.Errorf("wrote %s, want %s", out.String(), want)
+	}
+}
+
+// read-edn only takes the value that it reads from a port, leaving the rest
+// of the input for the next read
+func TestReadEDNFromPort(t *testing.T) {
+	e := newTestEvaluator(t)
+	e.SetInput(strings.NewReader("{:a 1}\n{:b 2} tail\nnext\n"))
+
+	var got []string
+	for _, src := range []string{`(read-edn)`, `(read-edn (current-input-port))`, `(read-line)`, `(read-line)`, `(read-edn)`} {
+		val, err := evalSource(e, src)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", src, err)
+		}
+		got = append(got, String(val))
+	}
+	want := `{:a 1} {:b 2} " tail" "next" nil`
+	if strings.Join(got, " ") != want {
+		t.Errorf("read %s, want %s", strings.Join(got, " "), want)
+	}
+
+	// Text pushed back by the gigl reader is read first
+	e.SetInput(strings.NewReader("x [1 2] y"))
+	if val, err := e.readFromPort(e.input); err != nil || val != SYMBOL("x") {
+		t.Fatalf("got %v, %v", val, err)
+	}
+	got = got[:0]
+	for _, src := range []string{`(read-edn)`, `(read)`} {
+		val, err := evalSource(e, src)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", src, err)
+		}
+		got = append(got, String(val))
+	}
+	if strings.Join(got, " ") != "[1 2] y" {
+		t.Errorf("read %s, want [1 2] y", strings.Join(got, " "))
+	}
+}
+
+func TestReadEDNOptions(t *testing.T) {
+	e := newTestEvaluator(t)
+	src := `(read-edn "#my/tag 2" :readers {my/tag (lambda (x) (* x 10))})`
+	if got, err := evalSource(e, src); err != nil || got != 20.0 {
+		t.Errorf("%s: got %v, %v", src, got, err)
+	}
+
+	for _, src := range []string{
+		`(read-edn "1" :reader {})`,
+		`(read-edn "1" {:readers {}})`,
+	} {
+		e := newTestEvaluator(t)
+		if _, err := evalSource(e, src); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
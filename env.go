@@ -1,11 +1,21 @@
 package gigl
 
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
 // An environment is a map of symbols to values that we can look up
 // bindings in, along with a reference to the enclosing environment
 // that we can backtrack to if we can't find something.
 type environment struct {
 	vals  map[SYMBOL]lispVal
 	outer *environment
+
+	// metadata about the bindings, such as the parameter list of a
+	// procedure defined with defn
+	meta map[SYMBOL]MAP
 }
 
 // Find attempts to find the closest environment that contains the
@@ -24,10 +34,67 @@ func (e *environment) find(sym SYMBOL) *environment {
 	return nil
 }
 
+// setMeta records the metadata for a binding in this environment
+func (e *environment) setMeta(sym SYMBOL, meta MAP) {
+	if e.meta == nil {
+		e.meta = make(map[SYMBOL]MAP)
+	}
+	e.meta[sym] = meta
+}
+
+// lookupMeta returns the metadata for the closest binding of sym
+func (e *environment) lookupMeta(sym SYMBOL) MAP {
+	if frame := e.find(sym); frame != nil {
+		return frame.meta[sym]
+	}
+	return nil
+}
+
+// names returns every symbol bound in this environment or its parents
+func (e *environment) names() []SYMBOL {
+	var names []SYMBOL
+	for frame := e; frame != nil; frame = frame.outer {
+		for sym := range frame.vals {
+			names = append(names, sym)
+		}
+	}
+	return names
+}
+
+func (e *environment) String() string {
+	return "#<environment>"
+}
+
+// create a new, empty environment: (make-environment [parent])
+// Without a parent the new environment sits directly inside the global one.
+func (e *Evaluator) makeEnvironment(lst ...lispVal) (lispVal, error) {
+	parent := e.globalEnv
+	switch len(lst) {
+	case 0:
+	case 1:
+		p, ok := lst[0].(*environment)
+		if !ok {
+			return nil, fmt.Errorf("make-environment: expected an environment: %v", String(lst[0]))
+		}
+		parent = p
+	default:
+		return nil, fmt.Errorf("make-environment takes an optional parent environment")
+	}
+	return &environment{vals: make(map[SYMBOL]lispVal), outer: parent}, nil
+}
+
+func isEnvironment(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("Type check on non-atom: %v", lst)
+	}
+	_, ok := lst[0].(*environment)
+	return ok, nil
+}
+
 // newGlobalEnvironment constructs a new global environment with the
 // predefined builtin functions.
 // NOTE :: builtins are found in builtin.go
-func newGlobalEnvironment(e Evaluator) *environment {
+func newGlobalEnvironment(e *Evaluator) *environment {
 	return &environment{
 		map[SYMBOL]lispVal{
 			"+":        add,
@@ -44,6 +111,8 @@ func newGlobalEnvironment(e Evaluator) *environment {
 			"!=":       notEqual,
 			"eq?":      isEqual,
 			"null?":    isNull,
+			"nil?":     isNil,
+			"bool?":    isBool,
 			"int?":     isInt,
 			"float?":   isFloat,
 			"string?":  isString,
@@ -56,11 +125,88 @@ func newGlobalEnvironment(e Evaluator) *environment {
 			"head":     car,
 			"tail":     cdr,
 			"len":      lispLength,
+			"get":      get,
 			"cons":     cons,
 			"append":   lispAppend,
 			"range":    makeRange,
 			"str":      str,
+
+			// String handling: see strings.go
+			"string-ref":     stringRef,
+			"substring":      substring,
+			"string-split":   stringSplit,
+			"string-join":    stringJoin,
+			"string-replace": stringReplace,
+			"upcase":         stringMapper("upcase", strings.ToUpper),
+			"downcase":       stringMapper("downcase", strings.ToLower),
+			"trim":           stringMapper("trim", strings.TrimSpace),
+			"starts-with?":   stringPredicate("starts-with?", strings.HasPrefix),
+			"ends-with?":     stringPredicate("ends-with?", strings.HasSuffix),
+			"contains?":      stringPredicate("contains?", strings.Contains),
+			"format":         format,
+			"string->number": stringToNumber,
+			"number->string": numberToString,
+
+			// Characters: see strings.go
+			"char?":            isChar,
+			"char->integer":    charToInteger,
+			"integer->char":    integerToChar,
+			"char-upcase":      charMapper("char-upcase", unicode.ToUpper),
+			"char-downcase":    charMapper("char-downcase", unicode.ToLower),
+			"char-alphabetic?": charPredicate("char-alphabetic?", unicode.IsLetter),
+			"char-numeric?":    charPredicate("char-numeric?", unicode.IsDigit),
+			"char-whitespace?": charPredicate("char-whitespace?", unicode.IsSpace),
+			"string->list":     stringToList,
+			"list->string":     listToString,
+
+			// Regular expressions: see regex.go
+			"regex?":     isRegex,
+			"re-pattern": rePattern,
+			"re-find":    reFind,
+			"re-matches": reMatches,
+			"re-seq":     reSeq,
+			"re-groups":  reGroups,
+			"re-replace": reReplace,
+
+			// Input and output: see io.go
+			"display":             e.display,
+			"write":               e.write,
+			"pp":                  e.pp,
+			"print":               e.print,
+			"println":             e.println,
+			"newline":             e.newline,
+			"read-line":           e.readLine,
+			"current-output-port": e.currentOutputPort,
+			"current-input-port":  e.currentInputPort,
+			"input-port?":         isInputPort,
+			"output-port?":        isOutputPort,
+			"open-input-file":     openInputFile,
+			"open-output-file":    openOutputFile,
+			"close-port":          lispClosePort,
+			"slurp":               slurp,
+			"spit":                spit,
+
+			// Reading and evaluating code at runtime
+			"read":             e.lispRead,
+			"read-string":      e.readString,
+			"eval":             e.lispEval,
+			"make-environment": e.makeEnvironment,
+			"environment?":     isEnvironment,
+			"features":         e.lispFeatures,
+			"apropos":          e.apropos,
+			"meta":             e.meta,
+			"with-meta":        e.withMeta,
+
+			// Data interchange: see edn.go, json.go and csv.go
+			"read-edn":       e.readEDN,
+			"write-edn":      e.writeEDN,
+			"json-parse":     jsonParse,
+			"json-stringify": jsonStringify,
+			"json-seq":       e.jsonSeq,
+			"csv-read":       csvRead,
+			"csv-write":      csvWrite,
 		},
 		nil,
+		nil,
 	}
 }
@@ -1,19 +1,84 @@
 package gigl
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync/atomic"
+)
 
 // Evaluator holds an execution environment and macrotable for running eval
 type Evaluator struct {
 	globalEnv  *environment
 	macroTable map[SYMBOL]lispVal
+	macroMeta  map[SYMBOL]MAP
+	sourcePos  string // where the top level form being evaluated came from
+	stop       atomic.Bool
+	input      *InputPort
+	output     *OutputPort
+	reader     *Tokeniser
 }
 
 // NewEvaluator ...
 func NewEvaluator() *Evaluator {
-	e := Evaluator{}
+	e := &Evaluator{reader: NewTokeniser()}
+	e.SetInput(os.Stdin)
+	e.SetOutput(os.Stdout)
 	e.globalEnv = newGlobalEnvironment(e)
 	e.macroTable = make(map[SYMBOL]lispVal)
-	return &e
+	e.macroMeta = make(map[SYMBOL]MAP)
+	e.documentBuiltins()
+	return e
+}
+
+// errInterrupted is returned by eval once interrupt has been called
+var errInterrupted = fmt.Errorf("Evaluation interrupted")
+
+// interrupt stops the evaluation in progress, which returns errInterrupted
+// at its next step. It is safe to call from another goroutine. Evaluation
+// stays stopped until resume is called.
+func (e *Evaluator) interrupt() {
+	e.stop.Store(true)
+}
+
+// resume allows evaluation to continue after an interrupt
+func (e *Evaluator) resume() {
+	e.stop.Store(false)
+}
+
+// EvalContext evaluates an expression in the global environment, giving
+// up if ctx is cancelled or times out first. The error then wraps both
+// errInterrupted and the error from ctx. Evaluation checks for
+// cancellation at every step, so even an infinite loop stops promptly
+// unless it is stuck inside a single builtin. Only one evaluation may be
+// in progress on an Evaluator at a time.
+func (e *Evaluator) EvalContext(ctx context.Context, expr lispVal) (lispVal, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInterrupted, err)
+	}
+
+	finished := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			e.interrupt()
+		case <-finished:
+		}
+	}()
+
+	result, err := e.eval(expr, nil)
+	close(finished)
+	<-stopped
+	e.resume()
+
+	if errors.Is(err, errInterrupted) && ctx.Err() != nil {
+		return nil, fmt.Errorf("%w: %w", errInterrupted, ctx.Err())
+	}
+	return result, err
 }
 
 // eval evaluates an expression in an environment
@@ -30,8 +95,12 @@ func (e *Evaluator) eval(expression lispVal, env *environment) (lispVal, error)
 	}
 
 	for {
+		if e.stop.Load() {
+			return nil, errInterrupted
+		}
+
 		switch expr := expression.(type) {
-		case float64, string, bool, KEYWORD, []lispVal, map[lispVal]lispVal:
+		case nil, float64, string, bool, KEYWORD, CHAR, REGEX, MAP, SET, VECTOR, *LazySeq, *InputPort, *OutputPort, *environment, []lispVal, map[lispVal]lispVal:
 			// Just return the value as is
 			return expr, nil
 
@@ -44,6 +113,11 @@ func (e *Evaluator) eval(expression lispVal, env *environment) (lispVal, error)
 			return nil, fmt.Errorf("Unknown symbol: %v", expr)
 
 		case *LispList:
+			// The empty list evaluates to itself
+			if expr.Len() == 0 {
+				return expr, nil
+			}
+
 			// Pull off the head of the list and see what we need to do
 			head, rest := expr.popHead()
 			head, ok := head.(SYMBOL)
@@ -83,66 +157,306 @@ func (e *Evaluator) eval(expression lispVal, env *environment) (lispVal, error)
 				return nil, fmt.Errorf("Cannot unquote outside of a quasi-quoted expression")
 
 			case "if":
-				// Evaluate the conditional and cast to a bool
+				// Evaluate the conditional and check its truthiness
+				if rest.Len() < 2 || rest.Len() > 3 {
+					return nil, fmt.Errorf("Malformed `if` form")
+				}
 				check, rest := rest.popHead()
 				check, err := e.eval(check, env)
 				if err != nil {
 					return nil, err
 				}
 				trueBranch, rest := rest.popHead()
-				if check.(bool) {
+				if isTruthy(check) {
 					// evaluate the true branch
 					return e.eval(trueBranch, env)
 				}
 
 				// evaluate the false branch or return nil
-				falseBranch, rest := rest.popHead()
-				if rest.length != 0 {
-					return nil, fmt.Errorf("Malformed `if` form")
+				if rest.Len() == 0 {
+					return nil, nil
 				}
+				falseBranch, _ := rest.popHead()
+				return e.eval(falseBranch, env)
 
-				if falseBranch != nil {
-					return e.eval(falseBranch, env)
+			case "cond":
+				// Evaluate the first branch whose test is truthy. A test of
+				// `else` or `:else` always matches.
+				var body *LispList
+				matched := false
+				for _, clause := range rest.toSlice() {
+					branch, ok := clause.(*LispList)
+					if !ok || branch.Len() == 0 {
+						return nil, fmt.Errorf("Invalid cond branch: %v", String(clause))
+					}
+					check, ifTrue := branch.popHead()
+					if check == SYMBOL("else") {
+						body, matched = ifTrue, true
+						result = nil
+						break
+					}
+					result, err = e.eval(check, env)
+					if err != nil {
+						return nil, err
+					}
+					if isTruthy(result) {
+						body, matched = ifTrue, true
+						break
+					}
+				}
+				if !matched {
+					return nil, nil
+				}
+				if body.Len() == 0 {
+					// A branch with no body returns the value of its test
+					return result, nil
+				}
+				// Loop back to evaluate the body of the matching branch
+				expression = consInternal(SYMBOL("begin"), body)
+
+			case "and":
+				// Short circuit on the first falsy value, returning the
+				// value of the last form if everything was truthy.
+				if rest.Len() == 0 {
+					return true, nil
+				}
+				var subExpr lispVal
+				allButOne := rest.Len() - 1
+				for i := 0; i < allButOne; i++ {
+					subExpr, rest = rest.popHead()
+					result, err = e.eval(subExpr, env)
+					if err != nil {
+						return nil, err
+					}
+					if !isTruthy(result) {
+						return result, nil
+					}
 				}
+				// Loop back to evaluate the last form and return it
+				expression = rest.Head()
 
-				return nil, nil
+			case "or":
+				// Short circuit on the first truthy value
+				if rest.Len() == 0 {
+					return false, nil
+				}
+				var subExpr lispVal
+				allButOne := rest.Len() - 1
+				for i := 0; i < allButOne; i++ {
+					subExpr, rest = rest.popHead()
+					result, err = e.eval(subExpr, env)
+					if err != nil {
+						return nil, err
+					}
+					if isTruthy(result) {
+						return result, nil
+					}
+				}
+				// Loop back to evaluate the last form and return it
+				expression = rest.Head()
 
-			case "cond":
-				valBranch, rest := rest.popHead()
-				branch, ok := valBranch.(*LispList)
-				if !ok {
-					return nil, fmt.Errorf("Invalid cond branch: %v", branch)
+			case "when", "unless":
+				// (when test body ...) => (if test (begin body ...))
+				if rest.Len() == 0 {
+					return nil, fmt.Errorf("Malformed `%v` form", head)
+				}
+				check, body := rest.popHead()
+				check, err := e.eval(check, env)
+				if err != nil {
+					return nil, err
+				}
+				if isTruthy(check) != (head == SYMBOL("when")) || body.Len() == 0 {
+					return nil, nil
 				}
+				// Loop back to evaluate the body
+				expression = consInternal(SYMBOL("begin"), body)
 
+			case "while":
+				// (while test body ...) repeats body for as long as test is truthy
+				if rest.Len() == 0 {
+					return nil, fmt.Errorf("Malformed `while` form")
+				}
+				check, body := rest.popHead()
 				for {
-					if rest.Len() == 0 && branch == nil {
+					result, err = e.eval(check, env)
+					if err != nil {
+						return nil, err
+					}
+					if !isTruthy(result) {
 						return nil, nil
 					}
-					check, ifTrue := branch.Head(), branch.Tail().Head()
-					check, err := e.eval(check, env)
+					for _, subExpr := range body.toSlice() {
+						if _, err = e.eval(subExpr, env); err != nil {
+							return nil, err
+						}
+					}
+				}
+
+			case "case":
+				// (case key ((datum ...) body ...) ... (else body ...))
+				if rest.Len() == 0 {
+					return nil, fmt.Errorf("Malformed `case` form")
+				}
+				key, clauses := rest.popHead()
+				key, err := e.eval(key, env)
+				if err != nil {
+					return nil, err
+				}
+				body, err := selectCaseClause(key, clauses)
+				if err != nil {
+					return nil, err
+				}
+				if body == nil || body.Len() == 0 {
+					return nil, nil
+				}
+				// Loop back to evaluate the body of the matching clause
+				expression = consInternal(SYMBOL("begin"), body)
+
+			case "do":
+				// (do ((var init step) ...) (test result ...) body ...)
+				if rest.Len() == 0 {
+					return nil, fmt.Errorf("Malformed `do` form: missing bindings")
+				}
+				specs, rest := rest.popHead()
+				exit, body := rest.popHead()
+				specList, ok := specs.(*LispList)
+				if !ok {
+					return nil, fmt.Errorf("Malformed `do` bindings: %v", String(specs))
+				}
+				exitList, ok := exit.(*LispList)
+				if !ok || exitList.Len() == 0 {
+					return nil, fmt.Errorf("Malformed `do` test clause: %v", String(exit))
+				}
+
+				// Bind the initial values in a fresh environment
+				syms := make([]SYMBOL, specList.Len())
+				steps := make([]lispVal, specList.Len())
+				loopEnv := &environment{vals: make(map[SYMBOL]lispVal), outer: env}
+				for i, spec := range specList.toSlice() {
+					binding, ok := spec.(*LispList)
+					if !ok || binding.Len() < 2 || binding.Len() > 3 {
+						return nil, fmt.Errorf("Malformed `do` binding: %v", String(spec))
+					}
+					parts := binding.toSlice()
+					sym, ok := parts[0].(SYMBOL)
+					if !ok {
+						return nil, fmt.Errorf("Attempt to bind non-symbol: %v", String(parts[0]))
+					}
+					init, err := e.eval(parts[1], env)
 					if err != nil {
 						return nil, err
 					}
-					switch check.(type) {
-					case bool:
-						if check.(bool) {
-							// evaluate the true branch
-							return e.eval(ifTrue, env)
-						}
-					case KEYWORD:
-						if check == KEYWORD("else") {
-							return e.eval(ifTrue, env)
+					syms[i] = sym
+					loopEnv.vals[sym] = init
+					if len(parts) == 3 {
+						steps[i] = parts[2]
+					}
+				}
+
+				test, results := exitList.popHead()
+				for {
+					done, err := e.eval(test, loopEnv)
+					if err != nil {
+						return nil, err
+					}
+					if isTruthy(done) {
+						break
+					}
+					for _, subExpr := range body.toSlice() {
+						if _, err = e.eval(subExpr, loopEnv); err != nil {
+							return nil, err
 						}
+					}
 
-						return nil, fmt.Errorf("Invalid cond condition: %v", branch)
+					// Each iteration gets fresh bindings so closures see
+					// the values from the iteration that created them.
+					nextEnv := &environment{vals: make(map[SYMBOL]lispVal), outer: env}
+					for i, sym := range syms {
+						if steps[i] == nil {
+							nextEnv.vals[sym] = loopEnv.vals[sym]
+							continue
+						}
+						nextEnv.vals[sym], err = e.eval(steps[i], loopEnv)
+						if err != nil {
+							return nil, err
+						}
 					}
+					loopEnv = nextEnv
+				}
 
-					valBranch, rest = rest.popHead()
-					branch, ok = valBranch.(*LispList)
-					if !ok {
-						return nil, fmt.Errorf("Invalid cond branch: %v", valBranch)
+				if results.Len() == 0 {
+					return nil, nil
+				}
+				// Loop back to evaluate the result forms
+				expression = consInternal(SYMBOL("begin"), results)
+				env = loopEnv
+
+			case "with-open-file":
+				// (with-open-file (port path [mode]) body ...)
+				spec, body := rest.popHead()
+				specList, ok := spec.(*LispList)
+				if !ok || specList.Len() < 2 || specList.Len() > 3 {
+					return nil, fmt.Errorf("Malformed `with-open-file` binding: %v", String(spec))
+				}
+				parts := specList.toSlice()
+				sym, ok := parts[0].(SYMBOL)
+				if !ok {
+					return nil, fmt.Errorf("Attempt to bind non-symbol: %v", String(parts[0]))
+				}
+				path, err := e.eval(parts[1], env)
+				if err != nil {
+					return nil, err
+				}
+				pathStr, err := getString(path)
+				if err != nil {
+					return nil, err
+				}
+				mode := lispVal(KEYWORD("read"))
+				if len(parts) == 3 {
+					if mode, err = e.eval(parts[2], env); err != nil {
+						return nil, err
 					}
 				}
+				modeKw, ok := mode.(KEYWORD)
+				if !ok {
+					return nil, fmt.Errorf("File mode must be a keyword: %v", String(mode))
+				}
+
+				port, err := openFilePort(pathStr, modeKw)
+				if err != nil {
+					return nil, err
+				}
+				innerEnv := &environment{
+					vals:  map[SYMBOL]lispVal{sym: port},
+					outer: env,
+				}
+				// The body can't be evaluated in tail position as the port
+				// needs closing once it has finished.
+				result, err = e.eval(consInternal(SYMBOL("begin"), body), innerEnv)
+				if closeErr := closePort(port); err == nil {
+					err = closeErr
+				}
+				if err != nil {
+					return nil, err
+				}
+				return result, nil
+
+			case "doc":
+				// (doc sym) describes what sym is bound to without evaluating it
+				sym, ok := rest.Head().(SYMBOL)
+				if !ok || rest.Len() != 1 {
+					return nil, fmt.Errorf("doc takes a single symbol")
+				}
+				text, err := e.docFor(sym, env)
+				if err != nil {
+					return nil, err
+				}
+				_, err = fmt.Fprintln(e.output.writer, text)
+				return nil, err
+
+			case "the-environment":
+				// Capture the current environment as a first class value
+				return env, nil
 
 			case "set!":
 				// find this symbol in its environment and update it
@@ -184,10 +498,16 @@ func (e *Evaluator) eval(expression lispVal, env *environment) (lispVal, error)
 					return nil, err
 				}
 				env.vals[sym.(SYMBOL)] = result
-				return nil, nil
+				if e.sourcePos != "" {
+					env.setMeta(sym.(SYMBOL), MAP{KEYWORD("source-pos"): e.sourcePos})
+				}
+				return sym, nil
 
 			case "lambda", "λ":
 				// Define a new procedure and return it
+				if rest.Len() < 2 {
+					return nil, fmt.Errorf("Malformed `%v` form", head)
+				}
 				params, rest := rest.popHead()
 				body, rest := rest.popHead()
 				return makeProc(params, body, env, e)
@@ -205,14 +525,20 @@ func (e *Evaluator) eval(expression lispVal, env *environment) (lispVal, error)
 					return nil, err
 				}
 
+				meta, rest := e.definitionMeta(rest)
+				if rest.Len() < 2 {
+					return nil, fmt.Errorf("Malformed `defn` form")
+				}
 				params, rest := rest.popHead()
 				body, rest := rest.popHead()
 				proc, err := makeProc(params, body, env, e)
 				if err != nil {
 					return nil, err
 				}
-				env.vals[sym.(SYMBOL)] = proc
-				return nil, nil
+				meta[KEYWORD("arglists")] = params
+				env.vals[sym.(SYMBOL)] = &Procedure{fn: proc, meta: meta}
+				env.setMeta(sym.(SYMBOL), meta)
+				return sym, nil
 
 			case "defmacro":
 				if env != e.globalEnv {
@@ -229,19 +555,31 @@ func (e *Evaluator) eval(expression lispVal, env *environment) (lispVal, error)
 					return nil, err
 				}
 
+				meta, rest := e.definitionMeta(rest)
+				if rest.Len() < 2 {
+					return nil, fmt.Errorf("Malformed `defmacro` form")
+				}
 				params, rest := rest.popHead()
 				body, rest := rest.popHead()
 				proc, err := makeProc(params, body, env, e)
 				if err != nil {
 					return nil, err
 				}
+				meta[KEYWORD("arglists")] = params
 				e.macroTable[sym.(SYMBOL)] = proc
-				return nil, nil
+				e.macroMeta[sym.(SYMBOL)] = meta
+				return sym, nil
 
 			case "let":
 				// (let ((parm val) ...) (body ...)) => ((lambda (parm ...) (begin body ...)) val ...)
 				// TODO :: Named let
+				if rest.Len() < 2 {
+					return nil, fmt.Errorf("Malformed `let` form")
+				}
 				bindings, rest := rest.popHead()
+				if _, ok := bindings.(*LispList); !ok {
+					return nil, fmt.Errorf("Malformed `let` bindings: %v", String(bindings))
+				}
 				body, rest := rest.popHead()
 				parms := List()
 				vals := List()
@@ -349,6 +687,22 @@ func (e *Evaluator) eval(expression lispVal, env *environment) (lispVal, error)
 	}
 }
 
+// evaluate a form at runtime: (eval form [env])
+func (e *Evaluator) lispEval(lst ...lispVal) (lispVal, error) {
+	switch len(lst) {
+	case 1:
+		return e.eval(lst[0], e.globalEnv)
+	case 2:
+		env, ok := lst[1].(*environment)
+		if !ok {
+			return nil, fmt.Errorf("eval: expected an environment: %v", String(lst[1]))
+		}
+		return e.eval(lst[0], env)
+	default:
+		return nil, fmt.Errorf("eval takes a form and an optional environment")
+	}
+}
+
 // apply a procedure to a list of arguments and return the result
 // NOTE: built-in/primative operations will execute without any outer environment,
 //		 procedures will bind their arguments before executing their statements.
@@ -357,6 +711,9 @@ func (e *Evaluator) apply(proc lispVal, args []lispVal) (lispVal, error) {
 	case func(...lispVal) (lispVal, error):
 		return p(args...)
 
+	case *Procedure:
+		return p.fn(args...)
+
 	default:
 		return nil, fmt.Errorf("Unknown procedure type: %v", p)
 	}
@@ -446,6 +803,38 @@ func (e *Evaluator) expandQuasiQuote(expression lispVal, env *environment) (lisp
 	}
 }
 
+// selectCaseClause finds the body of the first `case` clause whose datums
+// contain key, falling back to an `else` clause if there is one.
+func selectCaseClause(key lispVal, clauses *LispList) (*LispList, error) {
+	for _, clause := range clauses.toSlice() {
+		clause, ok := clause.(*LispList)
+		if !ok || clause.Len() == 0 {
+			return nil, fmt.Errorf("Invalid case clause: %v", String(clause))
+		}
+		datums, body := clause.popHead()
+		switch datums := datums.(type) {
+		case *LispList:
+			for _, datum := range datums.toSlice() {
+				if reflect.DeepEqual(key, datum) {
+					return body, nil
+				}
+			}
+		case SYMBOL, KEYWORD:
+			if datums == SYMBOL("else") || datums == KEYWORD("else") {
+				return body, nil
+			}
+			if reflect.DeepEqual(key, datums) {
+				return body, nil
+			}
+		default:
+			if reflect.DeepEqual(key, datums) {
+				return body, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
 func (e *Evaluator) getArgs(lst *LispList, env *environment) ([]lispVal, error) {
 	var elem lispVal
 	args := make([]lispVal, lst.Len())
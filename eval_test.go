@@ -0,0 +1,305 @@
+package gigl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// newTestEvaluator returns an evaluator with the prelude loaded that
+// discards anything written to standard output
+func newTestEvaluator(t *testing.T) *Evaluator {
+	t.Helper()
+	e := NewEvaluator()
+	e.SetOutput(io.Discard)
+	loadPrelude(e)
+	return e
+}
+
+// evalSource evaluates each of the forms in src, returning the value of
+// the last one
+func evalSource(e *Evaluator, src string) (lispVal, error) {
+	forms, err := e.reader.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	var result lispVal
+	for _, form := range forms {
+		if result, err = e.eval(form, nil); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func TestMalformedSpecialForms(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"(if)", "Malformed `if` form"},
+		{"(if #t)", "Malformed `if` form"},
+		{"(if #t 1 2 3)", "Malformed `if` form"},
+		{"(when)", "Malformed `when` form"},
+		{"(unless)", "Malformed `unless` form"},
+		{"(while)", "Malformed `while` form"},
+		{"(case)", "Malformed `case` form"},
+		{"(lambda)", "Malformed `lambda` form"},
+		{"(lambda (x))", "Malformed `lambda` form"},
+		{"(let)", "Malformed `let` form"},
+		{"(let ((x 1)))", "Malformed `let` form"},
+		{"(let x x)", "Malformed `let` bindings: x"},
+		{"(defn f)", "Malformed `defn` form"},
+		{"(defmacro m)", "Malformed `defmacro` form"},
+		{"(do)", "Malformed `do` form: missing bindings"},
+		{"(do x)", "Malformed `do` bindings: x"},
+		{"(do ())", "Malformed `do` test clause: ()"},
+		{"(do ((i 0)) x)", "Malformed `do` test clause: x"},
+		{"(do (i) (#t))", "Malformed `do` binding: i"},
+		{"(do ((i)) (#t))", "Malformed `do` binding: (i)"},
+		{"(do ((1 2)) (#t))", "Attempt to bind non-symbol: 1"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		_, err := evalSource(e, tt.src)
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.src)
+			continue
+		}
+		if err.Error() != tt.want {
+			t.Errorf("%s: got error %q, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestSpecialForms(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"(if #t 1)", "1"},
+		{"(if #f 1)", "nil"},
+		{"(when #t 1 2)", "2"},
+		{"(unless #t 1)", "nil"},
+		{"(define n 0) (while (< n 3) (set! n (+ n 1))) n", "3"},
+		{"((lambda (x) (* x 2)) 4)", "8"},
+		{"(let ((x 1) (y 2)) (+ x y))", "3"},
+		{"(and 1 #f 2)", "#f"},
+		{"(or #f nil 3)", "3"},
+		{"(case 2 ((1) :one) ((2 3) :few) (else :many))", ":few"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		got, err := evalSource(e, tt.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if String(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.src, String(got), tt.want)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		// The test is checked before each iteration, including the first
+		{"(do ((i 0 (+ i 1))) ((= i 3) i))", "3"},
+		{"(do ((i 5 (+ i 1))) (#t i))", "5"},
+
+		// Steps are evaluated using the bindings from the previous iteration
+		{"(do ((i 0 (+ i 1)) (acc '() (cons i acc))) ((= i 3) acc))", "(2 1 0)"},
+		{"(do ((a 1 b) (b 2 a) (n 0 (+ n 1))) ((= n 3) (list a b)))", "(2 1)"},
+
+		// Bindings without a step keep their value
+		{"(do ((i 0 (+ i 1)) (k 10)) ((= i 2) (+ i k)))", "12"},
+
+		// The body runs for its side effects
+		{"(define n 0) (do ((i 0 (+ i 1))) ((= i 4)) (set! n (+ n i))) n", "6"},
+
+		// The result forms are evaluated in order, returning the last
+		{"(define n 0) (do ((i 0 (+ i 1))) ((= i 2) (set! n i) (* n 10)))", "20"},
+		{"(do ((i 0 (+ i 1))) ((= i 2)))", "nil"},
+
+		// Closures made in the body see the iteration that made them
+		{"(define fs '()) (do ((i 0 (+ i 1))) ((= i 3)) (set! fs (cons (lambda () i) fs))) (map (lambda (f) (f)) fs)", "(2 1 0)"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		got, err := evalSource(e, tt.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if String(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.src, String(got), tt.want)
+		}
+	}
+}
+
+func TestIsTruthy(t *testing.T) {
+	tests := []struct {
+		val  lispVal
+		want bool
+	}{
+		{nil, false},
+		{false, false},
+		{true, true},
+		{0.0, true},
+		{"", true},
+		{List(), true},
+		{[]lispVal{}, true},
+		{MAP{}, true},
+		{SYMBOL("x"), true},
+		{KEYWORD("k"), true},
+	}
+
+	for _, tt := range tests {
+		if got := isTruthy(tt.val); got != tt.want {
+			t.Errorf("isTruthy(%s) = %v, want %v", String(tt.val), got, tt.want)
+		}
+	}
+}
+
+func TestTruthiness(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		// Only #f and nil are false
+		{"(if #f :yes :no)", ":no"},
+		{"(if nil :yes :no)", ":no"},
+		{"(if 0 :yes :no)", ":yes"},
+		{"(if \"\" :yes :no)", ":yes"},
+		{"(if '() :yes :no)", ":yes"},
+		{"(if [] :yes :no)", ":yes"},
+		{"(cond (#f 1) (nil 2) (0 3))", "3"},
+		{"(cond ('() 1) (else 2))", "1"},
+		{"(cond (#f 1))", "nil"},
+		{"(cond (:truthy))", ":truthy"},
+
+		// Definitions evaluate to the symbol they bind
+		{"(define x 1)", "x"},
+		{"(defn f (x) x)", "f"},
+		{"(defn g \"Docs.\" (x) x)", "g"},
+
+		// The empty list evaluates to itself
+		{"()", "()"},
+		{"'()", "()"},
+
+		// null? is defined for every value
+		{"(null? nil)", "#t"},
+		{"(null? '())", "#t"},
+		{"(null? '(1))", "#f"},
+		{"(null? #f)", "#f"},
+		{"(null? 0)", "#f"},
+		{"(null? \"\")", "#f"},
+		{"(null? [])", "#f"},
+		{"(null? :k)", "#f"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		got, err := evalSource(e, tt.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if String(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.src, String(got), tt.want)
+		}
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	defs := `
+		(define counter 0)
+		(defn spin-at (n) (if (= n 0) (while #t 1) (+ 1 (spin-at (- n 1)))))
+		(defn depth (n) (if (= n 0) 0 (+ 1 (depth (- n 1)))))`
+
+	tests := []string{
+		`(while #t 1)`,
+		`(while #t (set! counter (+ counter 1)))`,
+		// Non-tail recursion that is still deep in the Go stack when
+		// it is cancelled
+		`(spin-at 10000)`,
+		`(map (lambda (x) (spin-at x)) '(1 2))`,
+	}
+
+	for _, src := range tests {
+		e := newTestEvaluator(t)
+		if _, err := evalSource(e, defs); err != nil {
+			t.Fatal(err)
+		}
+		expr, err := e.reader.read(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		start := time.Now()
+		_, err = e.EvalContext(ctx, expr)
+		cancel()
+		if !errors.Is(err, errInterrupted) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: expected an interrupted error, got %v", src, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%s: took %v to stop", src, elapsed)
+		}
+
+		// The evaluator carries on as normal afterwards
+		got, err := evalSource(e, `(define after (depth 1000)) (+ after 1)`)
+		if err != nil || got != 1001.0 {
+			t.Errorf("%s: afterwards got %v, %v", src, got, err)
+		}
+		got, err = e.EvalContext(context.Background(), List(SYMBOL("depth"), 5.0))
+		if err != nil || got != 5.0 {
+			t.Errorf("%s: EvalContext afterwards got %v, %v", src, got, err)
+		}
+	}
+}
+
+func TestEvalContextCancel(t *testing.T) {
+	e := newTestEvaluator(t)
+	if _, err := evalSource(e, `(define x 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is evaluated with a context that is already cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := e.EvalContext(ctx, List(SYMBOL("set!"), SYMBOL("x"), 1.0))
+	if !errors.Is(err, errInterrupted) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected an interrupted error, got %v", err)
+	}
+	if x := e.globalEnv.vals["x"]; x != 0.0 {
+		t.Errorf("x was set to %v", x)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	expr, _ := e.reader.read(`(while #t (set! x (+ x 1)))`)
+	if _, err := e.EvalContext(ctx, expr); !errors.Is(err, errInterrupted) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected an interrupted error, got %v", err)
+	}
+
+	// Errors that have nothing to do with the context are left alone
+	_, err = e.EvalContext(context.Background(), List(SYMBOL("car"), 1.0))
+	if err == nil || errors.Is(err, errInterrupted) {
+		t.Errorf("expected an ordinary error, got %v", err)
+	}
+	if got, err := e.EvalContext(context.Background(), List(SYMBOL("+"), 1.0, 2.0)); err != nil || got != 3.0 {
+		t.Errorf("got %v, %v", got, err)
+	}
+}
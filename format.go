@@ -0,0 +1,328 @@
+package gigl
+
+import (
+	"strings"
+)
+
+/*
+	Source code formatting
+
+	Format parses source into a concrete syntax tree (see cst.go) and then
+	reprints it using the same layout engine as the pretty printer. Atoms
+	are printed exactly as they were written so that things like the
+	escapes in strings or the precision of numbers are left alone.
+	Comments just before a closing bracket are moved to after it so that
+	a bracket is never left on a line of its own.
+
+	Formatting is idempotent: formatting already formatted code leaves it
+	unchanged.
+*/
+
+// The width that the formatter tries to keep code within
+const formatWidth = 80
+
+// Forms whose bodies are always placed on the line after their head
+var definesProcedure = map[SYMBOL]bool{
+	"defn":     true,
+	"defmacro": true,
+}
+
+// Format reformats gigl source code with canonical indentation
+func Format(src string) (string, error) {
+	t := NewTokeniser()
+	// Report every syntax error rather than just the first
+	if _, err := t.ReadAll(src); err != nil {
+		return "", err
+	}
+	root, err := t.ReadCST(src)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, node := range root.Children {
+		writeLeadingComments(&b, node.Leading)
+		if err := render(&b, fmtDoc(node), formatWidth); err != nil {
+			return "", err
+		}
+		for i, c := range endComments(node) {
+			if i == 0 {
+				b.WriteString(" ")
+			} else {
+				b.WriteString("\n")
+			}
+			b.WriteString(commentText(c))
+		}
+		b.WriteString("\n")
+	}
+	writeLeadingComments(&b, root.CloseLeading)
+	if b.Len() == 0 {
+		return "", nil
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n", nil
+}
+
+// writeLeadingComments writes the comments before a top level form, keeping
+// a single blank line wherever there were any blank lines.
+func writeLeadingComments(b *strings.Builder, trivia []Trivia) {
+	// Leading trivia always starts at the beginning of a line
+	blank := true // whether the current line has been blank so far
+	for _, t := range trivia {
+		switch t.Kind {
+		case "NEWLINE":
+			if blank && b.Len() > 0 && !strings.HasSuffix(b.String(), "\n\n") {
+				b.WriteString("\n")
+			}
+			blank = true
+		case "COMMENT", "BLOCK_COMMENT":
+			b.WriteString(commentText(t) + "\n")
+			blank = false
+		}
+	}
+}
+
+// innerSource is the source of a node without its own leading and trailing
+// trivia
+func innerSource(n *CSTNode) string {
+	inner := *n
+	inner.Leading, inner.Trailing = nil, nil
+	return inner.Source()
+}
+
+func commentText(t Trivia) string {
+	return strings.TrimRight(t.Text, " \t\r")
+}
+
+// fmtDoc converts a node into a document for the layout engine. Atoms are
+// printed exactly as they were written. Comments at the end of the node are
+// left for the caller to place: see endComments.
+func fmtDoc(n *CSTNode) *doc {
+	switch n.Kind {
+	case "QUOTE", "DATUM_COMMENT", "READER_COND", "FEATURE_COND":
+		if keepsSource(n) {
+			// Nowhere sensible to put the comments so leave it alone
+			return text(innerSource(n))
+		}
+		// Datum comments in front of the form are children as well
+		parts := []*doc{text(n.Text), fmtDoc(n.Children[0])}
+		for _, child := range n.Children[1:] {
+			parts = append(parts, text(" "), fmtDoc(child))
+		}
+		return concat(parts...)
+	case "LIST":
+		if len(n.Children) > 0 && n.Children[0].Kind == "SYMBOL" {
+			return codeDoc(n)
+		}
+	case "MAP":
+		if d, ok := mapDoc(n); ok {
+			return d
+		}
+	case "VECTOR", "SET":
+	default:
+		return text(n.Text)
+	}
+	return group(bracket(n.Text, n.CloseText, align(joinFmt(n.Children, false))))
+}
+
+// keepsSource reports whether a quote or reader macro has comments that
+// can't be moved anywhere sensible, in which case it is printed as written
+func keepsSource(n *CSTNode) bool {
+	switch n.Kind {
+	case "QUOTE", "DATUM_COMMENT", "READER_COND", "FEATURE_COND":
+	default:
+		return false
+	}
+	for i, child := range n.Children {
+		if hasCommentTrivia(child.Leading) || (i < len(n.Children)-1 && len(endComments(child)) > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// endComments returns the comments that go at the end of a node's last
+// line. Comments just before a closing bracket are moved out after it so
+// that the bracket isn't left on a line of its own, followed by the node's
+// own trailing comment.
+func endComments(n *CSTNode) []Trivia {
+	var comments []Trivia
+	if len(n.Children) > 0 && !keepsSource(n) {
+		comments = endComments(n.Children[len(n.Children)-1])
+	}
+	for _, t := range n.CloseLeading {
+		if isComment(t.Kind) {
+			comments = append(comments, t)
+		}
+	}
+	for _, t := range n.Trailing {
+		if isComment(t.Kind) {
+			comments = append(comments, t)
+		}
+	}
+	return comments
+}
+
+// commentsDoc places comments one per line. The first one follows whatever
+// is already on the line and the caller has to end the line after the last.
+func commentsDoc(comments []Trivia) *doc {
+	parts := make([]*doc, 0, 2*len(comments))
+	for i, c := range comments {
+		if i > 0 {
+			parts = append(parts, hardLine())
+		}
+		parts = append(parts, text(commentText(c)))
+	}
+	return concat(parts...)
+}
+
+// mapDoc keeps each key on the same line as its value, as the pretty
+// printer does. A comment between a key and its value has to end its line
+// so then the value goes on the next one.
+func mapDoc(n *CSTNode) (*doc, bool) {
+	if len(n.Children)%2 != 0 {
+		return nil, false
+	}
+	entries := make([]fmtItem, 0, len(n.Children)/2)
+	for i := 0; i < len(n.Children); i += 2 {
+		key, val := n.Children[i], n.Children[i+1]
+		entry := []*doc{fmtDoc(key)}
+		comments := endComments(key)
+		if len(comments) == 0 && !hasCommentTrivia(val.Leading) {
+			entry = append(entry, text(" "), align(fmtDoc(val)))
+		} else {
+			if len(comments) > 0 {
+				entry = append(entry, text(" "), commentsDoc(comments))
+			}
+			entry = append(entry, hardLine())
+			for _, t := range val.Leading {
+				if isComment(t.Kind) {
+					entry = append(entry, text(commentText(t)), hardLine())
+				}
+			}
+			entry = append(entry, fmtDoc(val))
+		}
+		entries = append(entries, fmtItem{key.Leading, concat(entry...), endComments(val)})
+	}
+	return group(bracket(n.Text, n.CloseText, align(joinItems(entries, false)))), true
+}
+
+// codeDoc lays out a list that starts with a symbol in the same way as
+// listDoc in pretty.go
+func codeDoc(n *CSTNode) *doc {
+	head := n.Children[0]
+	args := n.Children[1:]
+	if hasCommentTrivia(head.Leading) {
+		return group(bracket("(", ")", align(joinFmt(n.Children, false))))
+	}
+	if len(args) == 0 {
+		return text("(" + head.Text + ")")
+	}
+	headComments := endComments(head)
+
+	count, special := specialIndent[SYMBOL(head.Text)]
+	if count > len(args) {
+		count = len(args)
+	}
+	// Comments have to end their line so a comment after the head pushes
+	// all of the arguments into the body
+	if len(headComments) > 0 {
+		count = 0
+	}
+	for i, a := range args[:count] {
+		if hasCommentTrivia(a.Leading) || (i < count-1 && len(endComments(a)) > 0) {
+			special = false
+		}
+	}
+
+	if !special {
+		argsDoc := joinFmt(args, false)
+		if len(headComments) > 0 {
+			argsDoc = concat(commentsDoc(headComments), hardLine(), argsDoc)
+		}
+		return group(bracket("("+head.Text+" ", ")", align(argsDoc)))
+	}
+
+	first := []*doc{text("(" + head.Text)}
+	for _, a := range args[:count] {
+		first = append(first, text(" "), fmtDoc(a))
+	}
+	// Only the first comment can stay on the first line: any others go on
+	// the lines before the body
+	firstComments := headComments
+	if count > 0 {
+		firstComments = nil
+		if count < len(args) {
+			firstComments = endComments(args[count-1])
+		}
+	}
+	body := joinFmt(args[count:], true)
+	if len(firstComments) > 0 {
+		first = append(first, text(" "+commentText(firstComments[0])))
+		rest := []*doc{hardLine()}
+		for _, c := range firstComments[1:] {
+			rest = append(rest, text(commentText(c)), hardLine())
+		}
+		body = concat(append(rest, joinFmt(args[count:], false))...)
+	} else if definesProcedure[SYMBOL(head.Text)] && count < len(args) {
+		// Procedure bodies always start on a new line
+		body = concat(hardLine(), joinFmt(args[count:], false))
+	}
+	return group(align(concat(concat(first...), nest(2, body), text(")"))))
+}
+
+func hasCommentTrivia(trivia []Trivia) bool {
+	for _, t := range trivia {
+		if isComment(t.Kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// fmtItem is something laid out by joinItems, which is either a node or a
+// key and value in a map, along with the comments before and after it
+type fmtItem struct {
+	leading []Trivia
+	doc     *doc
+	end     []Trivia
+}
+
+// joinFmt lays out nodes using joinItems
+func joinFmt(nodes []*CSTNode, leadingSep bool) *doc {
+	items := make([]fmtItem, len(nodes))
+	for i, node := range nodes {
+		items[i] = fmtItem{node.Leading, fmtDoc(node), endComments(node)}
+	}
+	return joinItems(items, leadingSep)
+}
+
+// joinItems separates items with line breaks along with their comments,
+// making sure that comments always end their line. The comments at the end
+// of the last item belong after the closing bracket so they are left for
+// the caller. If leadingSep is true then there is a break before the first
+// item as well.
+func joinItems(items []fmtItem, leadingSep bool) *doc {
+	parts := make([]*doc, 0, 2*len(items))
+	needSep := leadingSep
+	for i, item := range items {
+		for _, t := range item.leading {
+			if isComment(t.Kind) {
+				if needSep {
+					parts = append(parts, line())
+				}
+				parts = append(parts, text(commentText(t)), hardLine())
+				needSep = false
+			}
+		}
+		if needSep {
+			parts = append(parts, line())
+		}
+		parts = append(parts, item.doc)
+		needSep = true
+		if i < len(items)-1 && len(item.end) > 0 {
+			parts = append(parts, text(" "), commentsDoc(item.end), hardLine())
+			needSep = false
+		}
+	}
+	return concat(parts...)
+}
@@ -0,0 +1,67 @@
+package gigl
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestFormatGolden formats each testdata/fmt/*.ggl file and compares the
+// result with the matching .golden file. Run with -update to regenerate
+// them after an intended change to the formatter.
+func TestFormatGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "fmt", "*.ggl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no test files found")
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Format(string(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := strings.TrimSuffix(path, ".ggl") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("formatted output differs from %s:\n%s", golden, got)
+			}
+
+			again, err := Format(got)
+			if err != nil {
+				t.Fatalf("formatted output doesn't parse: %v", err)
+			}
+			if again != got {
+				t.Errorf("formatting isn't idempotent, second pass gave:\n%s", again)
+			}
+		})
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	for _, src := range []string{"(define x", "(define x))", `"unterminated`} {
+		if _, err := Format(src); err == nil {
+			t.Errorf("%q: expected an error", src)
+		}
+	}
+}
@@ -1,9 +1,147 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
 	"github.com/sminez/gigl"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fmt":
+			os.Exit(runFmt(os.Args[2:]))
+		case "serve":
+			os.Exit(runServe(os.Args[2:]))
+		case "lsp":
+			// The client talks to the server over stdin and stdout
+			if err := gigl.ServeLSP(os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
 	gigl.REPL()
 }
+
+// gigl serve [--host addr] [--port n]
+// Runs an nREPL server until interrupted. The port is written to
+// .nrepl-port in the current directory so that editors can find it.
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	host := flags.String("host", "127.0.0.1", "the address to listen on")
+	port := flags.Int("port", 0, "the port to listen on (a free port if 0)")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gigl serve [--host addr] [--port n]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	l, err := net.Listen("tcp", net.JoinHostPort(*host, strconv.Itoa(*port)))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	addr := l.Addr().(*net.TCPAddr)
+	fmt.Printf("nREPL server started on port %d on host %s - nrepl://%s\n", addr.Port, *host, addr)
+
+	portFile := ".nrepl-port"
+	if err := os.WriteFile(portFile, []byte(strconv.Itoa(addr.Port)), 0644); err != nil {
+		portFile = ""
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		l.Close()
+	}()
+
+	err = gigl.NewNREPLServer().Serve(l)
+	if portFile != "" {
+		os.Remove(portFile)
+	}
+	if !errors.Is(err, net.ErrClosed) {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// gigl fmt [-w] files...
+// Formats each file, printing the result to stdout or writing it back to
+// the file with -w. With no files, stdin is formatted to stdout.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result back to the source file instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gigl fmt [-w] [files...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		formatted, err := gigl.Format(string(src))
+		if err != nil {
+			reportError("<stdin>", err)
+			return 1
+		}
+		fmt.Print(formatted)
+		return 0
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		if err := fmtFile(path, *write); err != nil {
+			reportError(path, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// reportError prints each line of an error prefixed with the file it is in
+func reportError(path string, err error) {
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, line)
+	}
+}
+
+func fmtFile(path string, write bool) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	formatted, err := gigl.Format(string(src))
+	if err != nil {
+		return err
+	}
+
+	if !write {
+		_, err = fmt.Print(formatted)
+		return err
+	}
+	if formatted == string(src) {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(formatted), info.Mode().Perm())
+}
@@ -0,0 +1,197 @@
+package gigl
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/chzyer/readline"
+)
+
+/*
+	Inspector
+
+	,inspect expr shows the entries of a collection one per line, numbered
+	so that any of them can be walked into in turn:
+
+	  n   inspect entry n
+	  u   go back up to the enclosing value
+	  p   pretty print the current value
+	  q   leave the inspector (as does Ctrl-D or Ctrl-C)
+
+	Lazy sequences are only realised as far as inspectLimit entries.
+*/
+
+var InspectPrompt = "inspect > "
+
+// The number of entries of a lazy sequence that are shown
+const inspectLimit = 100
+
+// inspectEntry is a value in the inspector along with how it was reached
+type inspectEntry struct {
+	label string
+	val   lispVal
+}
+
+// inspect walks into a value interactively until the user quits
+func (s *replSession) inspect(label string, val lispVal) error {
+	defer s.rl.SetPrompt(InPrompt)
+	s.rl.SetPrompt(InspectPrompt)
+
+	path := []inspectEntry{{label, val}}
+	show := true
+	for {
+		current := path[len(path)-1]
+		entries, more := inspectEntries(current.val)
+		if show {
+			showInspected(s.out, path, entries, more)
+		}
+		show = true
+
+		line, err := s.rl.Readline()
+		if err == readline.ErrInterrupt || err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch cmd := strings.TrimSpace(line); cmd {
+		case "q":
+			return nil
+		case "u", "..":
+			if len(path) > 1 {
+				path = path[:len(path)-1]
+			}
+		case "p":
+			printResult(s.out, current.val)
+			show = false
+		default:
+			n, err := strconv.Atoi(cmd)
+			if err != nil || n < 0 || n >= len(entries) {
+				fmt.Fprintln(s.out, OutPrompt, "Enter an entry number, u to go up, p to print or q to quit")
+				show = false
+				continue
+			}
+			path = append(path, entries[n])
+		}
+	}
+}
+
+// showInspected prints where we are in the value being inspected followed
+// by its entries
+func showInspected(w io.Writer, path []inspectEntry, entries []inspectEntry, more bool) {
+	labels := make([]string, len(path))
+	for i, entry := range path {
+		labels[i] = entry.label
+	}
+	current := path[len(path)-1].val
+
+	fmt.Fprintln(w, OutPrompt, strings.Join(labels, " > "))
+	if entries == nil {
+		fmt.Fprintln(w, OutPrompt, typeName(current)+":", clip(String(current), 70))
+		return
+	}
+	fmt.Fprintf(w, "%s %s of %d entries\n", OutPrompt, typeName(current), len(entries))
+	width := len(strconv.Itoa(len(entries) - 1))
+	_, isMap := current.(MAP)
+	for i, entry := range entries {
+		text := clip(String(entry.val), 60)
+		if isMap {
+			text = clip(entry.label, 20) + " " + text
+		}
+		fmt.Fprintf(w, "%s   %*d  %s\n", OutPrompt, width, i, text)
+	}
+	if more {
+		fmt.Fprintln(w, OutPrompt, "  ...")
+	}
+}
+
+// inspectEntries lists the entries of a collection, reporting whether a
+// lazy sequence has more entries than are shown. Anything else has none.
+func inspectEntries(val lispVal) ([]inspectEntry, bool) {
+	indexed := func(vals []lispVal) []inspectEntry {
+		entries := make([]inspectEntry, len(vals))
+		for i, v := range vals {
+			entries[i] = inspectEntry{strconv.Itoa(i), v}
+		}
+		return entries
+	}
+
+	switch v := val.(type) {
+	case *LispList, []lispVal, VECTOR:
+		return indexed(children(v)), false
+	case MAP:
+		keys := make([]lispVal, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		entries := make([]inspectEntry, 0, len(v))
+		for _, k := range new(printer).sortedKeys(keys) {
+			entries = append(entries, inspectEntry{String(k), v[k]})
+		}
+		return entries, false
+	case SET:
+		return indexed(new(printer).sortedKeys(children(v))), false
+	case *LazySeq:
+		var vals []lispVal
+		for seq := v; ; seq = seq.tail {
+			empty, err := seq.IsEmpty()
+			if err != nil || empty {
+				return indexed(vals), false
+			}
+			if len(vals) == inspectLimit {
+				return indexed(vals), true
+			}
+			vals = append(vals, seq.head)
+		}
+	}
+	return nil, false
+}
+
+// typeName describes the type of a value for the inspector
+func typeName(val lispVal) string {
+	switch v := val.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case CHAR:
+		return "character"
+	case SYMBOL:
+		return "symbol"
+	case KEYWORD:
+		return "keyword"
+	case REGEX:
+		return "regex"
+	case *LispList:
+		return "list"
+	case []lispVal, VECTOR:
+		return "vector"
+	case MAP:
+		return "map"
+	case SET:
+		return "set"
+	case *LazySeq:
+		return "lazy sequence"
+	case func(...lispVal) (lispVal, error), *Procedure:
+		return "procedure"
+	case error:
+		return "error"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// clip shortens text to at most n runes
+func clip(text string, n int) string {
+	if runes := []rune(text); len(runes) > n {
+		return string(runes[:n-3]) + "..."
+	}
+	return text
+}
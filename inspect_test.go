@@ -0,0 +1,100 @@
+package gigl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	s, out := newTestSession(t, "1", "0", "u", "2", "p", "9", "x", "q", "not reached")
+	if err := s.command(`,inspect [1 {:b "two" :a [3 4]} '(5)]`); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`[1 {:b "two" :a [3 4]} '(5)]`,
+		`vector of 3 entries`,
+		`  0  1`,
+		`  1  {:a [3 4], :b "two"}`,
+		`  2  (quote (5))`,
+		// 1
+		`[1 {:b "two" :a [3 4]} '(5)] > 1`,
+		`map of 2 entries`,
+		`  0  :a [3 4]`,
+		`  1  :b "two"`,
+		// 0
+		`[1 {:b "two" :a [3 4]} '(5)] > 1 > :a`,
+		`vector of 2 entries`,
+		`  0  3`,
+		`  1  4`,
+		// u
+		`[1 {:b "two" :a [3 4]} '(5)] > 1`,
+		`map of 2 entries`,
+		`  0  :a [3 4]`,
+		`  1  :b "two"`,
+		// 2 and 9 are out of range, as is x
+		`Enter an entry number, u to go up, p to print or q to quit`,
+		`{:a [3 4], :b "two"}`,
+		`Enter an entry number, u to go up, p to print or q to quit`,
+		`Enter an entry number, u to go up, p to print or q to quit`,
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		got = append(got, strings.TrimPrefix(line, OutPrompt+" "))
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("inspector wrote:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	rl := s.rl.(*scriptedLines)
+	if len(rl.lines) != 1 {
+		t.Errorf("q didn't leave the inspector: %q left", rl.lines)
+	}
+	if rl.prompt != InPrompt {
+		t.Errorf("prompt left as %q", rl.prompt)
+	}
+}
+
+func TestInspectValues(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`42`, "number: 42"},
+		{`"text"`, `string: "text"`},
+		{`#{:b :a}`, "set of 2 entries\n  0  :a\n  1  :b"},
+		{`'()`, "list of 0 entries"},
+		{`car`, "procedure: #<procedure>"},
+		{`(range 3)`, "list of 3 entries\n  0  0\n  1  1\n  2  2"},
+	}
+
+	for _, tt := range tests {
+		s, out := newTestSession(t)
+		if err := s.command(",inspect " + tt.src); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		for i := range lines {
+			lines[i] = strings.TrimPrefix(lines[i], OutPrompt+" ")
+		}
+		if got := strings.Join(lines[1:], "\n"); got != tt.want {
+			t.Errorf("%s: inspector wrote %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+// Lazy sequences are only realised as far as the inspector shows them
+func TestInspectLazySeq(t *testing.T) {
+	n := 0
+	seq := NewLazySeq(func() (lispVal, bool, error) {
+		n++
+		return float64(n), true, nil
+	})
+	entries, more := inspectEntries(seq)
+	if len(entries) != inspectLimit || !more {
+		t.Errorf("got %d entries, more %v", len(entries), more)
+	}
+	if n > inspectLimit+1 {
+		t.Errorf("realised %d entries", n)
+	}
+}
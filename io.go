@@ -0,0 +1,425 @@
+package gigl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+	Input and output
+
+	Ports wrap an io.Reader or io.Writer. Every Evaluator has a current
+	input and output port (stdin and stdout by default) which the printing
+	builtins use unless they are given a port explicitly. Embedders can
+	redirect these with SetInput and SetOutput.
+*/
+
+// An InputPort is a source of text for reading
+type InputPort struct {
+	name    string
+	reader  *bufio.Reader
+	pending string // text that was pushed back to be read again first
+	closer  io.Closer
+
+	// How to undo the last ReadRune: the text it took from pending, or
+	// whether it came from the reader instead
+	lastRune       string
+	lastFromReader bool
+}
+
+// An OutputPort is a destination for text
+type OutputPort struct {
+	name   string
+	writer io.Writer
+	closer io.Closer
+}
+
+// NewInputPort wraps an io.Reader. If r is also an io.Closer then it will
+// be closed along with the port.
+func NewInputPort(name string, r io.Reader) *InputPort {
+	p := &InputPort{name: name, reader: bufio.NewReader(r)}
+	if c, ok := r.(io.Closer); ok {
+		p.closer = c
+	}
+	return p
+}
+
+// NewOutputPort wraps an io.Writer. If w is also an io.Closer then it will
+// be closed along with the port.
+func NewOutputPort(name string, w io.Writer) *OutputPort {
+	p := &OutputPort{name: name, writer: w}
+	if c, ok := w.(io.Closer); ok {
+		p.closer = c
+	}
+	return p
+}
+
+// Read implements io.Reader, starting with any text that was pushed back
+func (p *InputPort) Read(b []byte) (int, error) {
+	p.forgetRune()
+	if p.pending != "" {
+		n := copy(b, p.pending)
+		p.pending = p.pending[n:]
+		return n, nil
+	}
+	return p.reader.Read(b)
+}
+
+// readString reads up to and including the first occurrence of delim, as
+// bufio.Reader.ReadString does
+func (p *InputPort) readString(delim byte) (string, error) {
+	p.forgetRune()
+	if i := strings.IndexByte(p.pending, delim); i >= 0 {
+		text := p.pending[:i+1]
+		p.pending = p.pending[i+1:]
+		return text, nil
+	}
+	text, err := p.reader.ReadString(delim)
+	text, p.pending = p.pending+text, ""
+	return text, err
+}
+
+// unread pushes text back so that it is the next thing read from the port
+func (p *InputPort) unread(text string) {
+	p.forgetRune()
+	p.pending = text + p.pending
+}
+
+// ReadRune implements io.RuneScanner along with UnreadRune. Decoders that
+// are given a RuneScanner read from it directly rather than wrapping it in
+// a buffer of their own, which would take input away from the port.
+func (p *InputPort) ReadRune() (rune, int, error) {
+	p.forgetRune()
+	if p.pending != "" {
+		r, size := utf8.DecodeRuneInString(p.pending)
+		p.lastRune, p.pending = p.pending[:size], p.pending[size:]
+		return r, size, nil
+	}
+	r, size, err := p.reader.ReadRune()
+	p.lastFromReader = err == nil
+	return r, size, err
+}
+
+// UnreadRune puts back the rune read by the last call to ReadRune
+func (p *InputPort) UnreadRune() error {
+	switch {
+	case p.lastFromReader:
+		if err := p.reader.UnreadRune(); err != nil {
+			return err
+		}
+	case p.lastRune != "":
+		p.pending = p.lastRune + p.pending
+	default:
+		return bufio.ErrInvalidUnreadRune
+	}
+	p.forgetRune()
+	return nil
+}
+
+func (p *InputPort) forgetRune() {
+	p.lastRune, p.lastFromReader = "", false
+}
+
+// Close the underlying reader if it can be closed
+func (p *InputPort) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	err := p.closer.Close()
+	p.closer = nil
+	return err
+}
+
+// Close the underlying writer if it can be closed
+func (p *OutputPort) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	err := p.closer.Close()
+	p.closer = nil
+	return err
+}
+
+func (p *InputPort) String() string {
+	return fmt.Sprintf("#<input-port %s>", p.name)
+}
+
+func (p *OutputPort) String() string {
+	return fmt.Sprintf("#<output-port %s>", p.name)
+}
+
+// SetOutput redirects the current output port of the evaluator
+func (e *Evaluator) SetOutput(w io.Writer) {
+	e.output = NewOutputPort("output", w)
+	// Never close a writer that belongs to the embedder
+	e.output.closer = nil
+}
+
+// SetInput redirects the current input port of the evaluator
+func (e *Evaluator) SetInput(r io.Reader) {
+	e.input = NewInputPort("input", r)
+	e.input.closer = nil
+}
+
+// Open a file as a port. Mode is one of :read, :write or :append.
+func openFilePort(path string, mode KEYWORD) (lispVal, error) {
+	switch mode {
+	case "read":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		return NewInputPort(path, f), nil
+
+	case "write":
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		return NewOutputPort(path, f), nil
+
+	case "append":
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return NewOutputPort(path, f), nil
+
+	default:
+		return nil, fmt.Errorf("Unknown file mode: %v", String(mode))
+	}
+}
+
+// Close either kind of port
+func closePort(port lispVal) error {
+	switch p := port.(type) {
+	case *InputPort:
+		return p.Close()
+	case *OutputPort:
+		return p.Close()
+	default:
+		return fmt.Errorf("Attempt to close a non-port: %v", String(port))
+	}
+}
+
+// Pull an optional trailing port out of an argument list
+func (e *Evaluator) outputPortArg(name string, lst []lispVal, nargs int) (*OutputPort, error) {
+	switch len(lst) {
+	case nargs:
+		return e.output, nil
+	case nargs + 1:
+		p, ok := lst[nargs].(*OutputPort)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected an output port: %v", name, String(lst[nargs]))
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("%s takes %d arguments and an optional port", name, nargs)
+	}
+}
+
+func (e *Evaluator) inputPortArg(name string, lst []lispVal) (*InputPort, error) {
+	switch len(lst) {
+	case 0:
+		return e.input, nil
+	case 1:
+		p, ok := lst[0].(*InputPort)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected an input port: %v", name, String(lst[0]))
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("%s takes an optional port", name)
+	}
+}
+
+// write a value for humans: (display x [port])
+func (e *Evaluator) display(lst ...lispVal) (lispVal, error) {
+	port, err := e.outputPortArg("display", lst, 1)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(port.writer, Display(lst[0]))
+	return nil, err
+}
+
+// write a value so that it can be read back in: (write x [port])
+func (e *Evaluator) write(lst ...lispVal) (lispVal, error) {
+	port, err := e.outputPortArg("write", lst, 1)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(port.writer, String(lst[0]))
+	return nil, err
+}
+
+// write a newline: (newline [port])
+func (e *Evaluator) newline(lst ...lispVal) (lispVal, error) {
+	port, err := e.outputPortArg("newline", lst, 0)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(port.writer, "\n")
+	return nil, err
+}
+
+// Pull an optional trailing port off the arguments to a variadic builtin
+func (e *Evaluator) trailingPortArg(lst []lispVal) (*OutputPort, []lispVal) {
+	if n := len(lst); n > 0 {
+		if p, ok := lst[n-1].(*OutputPort); ok {
+			return p, lst[:n-1]
+		}
+	}
+	return e.output, lst
+}
+
+// display all arguments separated by spaces: (print x ... [port])
+func (e *Evaluator) print(lst ...lispVal) (lispVal, error) {
+	port, lst := e.trailingPortArg(lst)
+	return nil, printTo(port, lst)
+}
+
+// display all arguments separated by spaces followed by a newline:
+// (println x ... [port])
+func (e *Evaluator) println(lst ...lispVal) (lispVal, error) {
+	port, lst := e.trailingPortArg(lst)
+	if err := printTo(port, lst); err != nil {
+		return nil, err
+	}
+	return e.newline(port)
+}
+
+func printTo(port *OutputPort, lst []lispVal) error {
+	strs := make([]string, len(lst))
+	for i, v := range lst {
+		strs[i] = Display(v)
+	}
+	_, err := io.WriteString(port.writer, strings.Join(strs, " "))
+	return err
+}
+
+// read a line of text without its line ending, or nil at end of input
+func (e *Evaluator) readLine(lst ...lispVal) (lispVal, error) {
+	port, err := e.inputPortArg("read-line", lst)
+	if err != nil {
+		return nil, err
+	}
+	line, err := port.readString('\n')
+	if err == io.EOF {
+		if line == "" {
+			return nil, nil
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (e *Evaluator) currentOutputPort(lst ...lispVal) (lispVal, error) {
+	return e.output, nil
+}
+
+func (e *Evaluator) currentInputPort(lst ...lispVal) (lispVal, error) {
+	return e.input, nil
+}
+
+func isInputPort(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("Type check on non-atom: %v", lst)
+	}
+	_, ok := lst[0].(*InputPort)
+	return ok, nil
+}
+
+func isOutputPort(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("Type check on non-atom: %v", lst)
+	}
+	_, ok := lst[0].(*OutputPort)
+	return ok, nil
+}
+
+func openInputFile(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("open-input-file takes a single path")
+	}
+	path, err := getString(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	return openFilePort(path, "read")
+}
+
+// (open-output-file path [:append])
+func openOutputFile(lst ...lispVal) (lispVal, error) {
+	if len(lst) < 1 || len(lst) > 2 {
+		return nil, fmt.Errorf("open-output-file takes a path and an optional mode")
+	}
+	path, err := getString(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	mode := KEYWORD("write")
+	if len(lst) == 2 {
+		kw, ok := lst[1].(KEYWORD)
+		if !ok || (kw != "write" && kw != "append") {
+			return nil, fmt.Errorf("open-output-file: mode must be :write or :append: %v", String(lst[1]))
+		}
+		mode = kw
+	}
+	return openFilePort(path, mode)
+}
+
+func lispClosePort(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("close-port takes a single port")
+	}
+	return nil, closePort(lst[0])
+}
+
+// read the entire contents of a file into a string
+func slurp(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("slurp takes a single path")
+	}
+	path, err := getString(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return string(contents), nil
+}
+
+// write a value to a file, replacing its contents: (spit path x [:append #t])
+func spit(lst ...lispVal) (lispVal, error) {
+	opts, err := keywordArgs("spit", lst, 2, "append")
+	if err != nil {
+		return nil, err
+	}
+	path, err := getString(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	mode := KEYWORD("write")
+	if isTruthy(opts["append"]) {
+		mode = "append"
+	}
+
+	port, err := openFilePort(path, mode)
+	if err != nil {
+		return nil, err
+	}
+	out := port.(*OutputPort)
+	_, err = io.WriteString(out.writer, Display(lst[1]))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return nil, err
+}
@@ -0,0 +1,132 @@
+package gigl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrintingToPorts(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`(display "a")`, `a`},
+		{`(write "a")`, `"a"`},
+		{`(print 1 "b" :c)`, `1 b :c`},
+		{`(println 1 2)`, "1 2\n"},
+		{`(println)`, "\n"},
+		{`(print 1 (current-output-port))`, `1`},
+		{`(println 1 2 (current-output-port))`, "1 2\n"},
+		{`(newline (current-output-port))`, "\n"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		var out bytes.Buffer
+		e.SetOutput(&out)
+		if _, err := evalSource(e, tt.src); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: wrote %q, want %q", tt.src, out.String(), tt.want)
+		}
+	}
+}
+
+func TestOutputFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	e := newTestEvaluator(t)
+	src := strings.ReplaceAll(`
+		(define p (open-output-file "PATH"))
+		(println "one" p)
+		(close-port p)
+		(define q (open-output-file "PATH" :append))
+		(print "two" 2 q)
+		(close-port q)`, "PATH", path)
+	if _, err := evalSource(e, src); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "one\ntwo 2" {
+		t.Errorf("file contains %q", got)
+	}
+
+	for _, mode := range []string{`:read`, `:apend`, `"append"`} {
+		src := `(open-output-file "` + path + `" ` + mode + `)`
+		if _, err := evalSource(e, src); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
+
+func TestReadFromPort(t *testing.T) {
+	e := newTestEvaluator(t)
+	e.SetInput(strings.NewReader("1 (2 3) :four\nrest of line\n\"five\"\n"))
+	reader := e.input.reader
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		val, err := e.readFromPort(e.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, String(val))
+	}
+	line, err := e.readLine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, String(line))
+	val, err := e.readFromPort(e.input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, String(val))
+
+	want := `1 (2 3) :four "rest of line" "five"`
+	if strings.Join(got, " ") != want {
+		t.Errorf("read %s, want %s", strings.Join(got, " "), want)
+	}
+	if e.input.reader != reader {
+		t.Errorf("reading replaced the reader of the port")
+	}
+
+	// Many forms on one line are all read from the same port
+	e.SetInput(strings.NewReader(strings.Repeat("x ", 1000)))
+	for i := 0; i < 1000; i++ {
+		if val, err := e.readFromPort(e.input); err != nil || val != SYMBOL("x") {
+			t.Fatalf("form %d: got %v, %v", i, val, err)
+		}
+	}
+	if val, err := e.readFromPort(e.input); err != nil || val != nil {
+		t.Errorf("expected nil at the end of the input, got %v, %v", val, err)
+	}
+}
+
+func TestSpitAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	e := newTestEvaluator(t)
+	src := strings.ReplaceAll(`(spit "PATH" "a") (spit "PATH" "b" :append #t)`, "PATH", path)
+	if _, err := evalSource(e, src); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "ab" {
+		t.Errorf("file contains %q", got)
+	}
+
+	src = `(spit "` + path + `" 1 :apend #t)`
+	if _, err := evalSource(e, src); err == nil {
+		t.Errorf("%s: expected an error", src)
+	}
+}
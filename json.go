@@ -0,0 +1,334 @@
+package gigl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	JSON
+
+	Objects are read as MAPs with string keys (or keywords if asked for)
+	and arrays as vectors. Numbers are decoded from their source text so
+	integers come through exactly, and integers too large for a float64 to
+	hold exactly are an error rather than being rounded. Floats with no
+	fractional part are written back out as integers rather than in
+	exponent form.
+
+	json-stringify accepts keywords, symbols and characters as strings and
+	any list, vector, set or lazy sequence as an array.
+*/
+
+// jsonOptions are the settings given as :key value options, such as
+// (json-stringify x :pretty #t :indent 4)
+type jsonOptions struct {
+	keywordKeys bool
+	indent      string
+}
+
+func getJSONOptions(name string, opts map[KEYWORD]lispVal) (jsonOptions, error) {
+	var o jsonOptions
+	switch keys := opts["keys"]; keys {
+	case nil, KEYWORD("string"):
+	case KEYWORD("keyword"):
+		o.keywordKeys = true
+	default:
+		return o, fmt.Errorf("%s: :keys must be :string or :keyword: %v", name, String(keys))
+	}
+
+	if isTruthy(opts["pretty"]) {
+		o.indent = "  "
+	}
+	switch indent := opts["indent"].(type) {
+	case nil:
+	case string:
+		o.indent = indent
+	case float64:
+		o.indent = strings.Repeat(" ", int(indent))
+	default:
+		return o, fmt.Errorf("%s: :indent must be a string or a number: %v", name, String(indent))
+	}
+	return o, nil
+}
+
+// jsonToLisp converts a value decoded with UseNumber into a gigl value
+func jsonToLisp(v interface{}, o jsonOptions) (lispVal, error) {
+	switch x := v.(type) {
+	case json.Number:
+		return jsonNumberToLisp(x)
+	case []interface{}:
+		vals := make([]lispVal, len(x))
+		for i, item := range x {
+			val, err := jsonToLisp(item, o)
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = val
+		}
+		return vals, nil
+	case map[string]interface{}:
+		m := make(MAP, len(x))
+		for k, item := range x {
+			val, err := jsonToLisp(item, o)
+			if err != nil {
+				return nil, err
+			}
+			if o.keywordKeys {
+				m[KEYWORD(k)] = val
+			} else {
+				m[k] = val
+			}
+		}
+		return m, nil
+	default:
+		// strings, booleans and null
+		return v, nil
+	}
+}
+
+// jsonNumberToLisp converts a JSON number to a float64, refusing integers
+// that a float64 can't hold exactly rather than silently rounding them
+func jsonNumberToLisp(n json.Number) (lispVal, error) {
+	if !strings.ContainsAny(string(n), ".eE") {
+		i, ok := new(big.Int).SetString(string(n), 10)
+		if !ok {
+			return nil, fmt.Errorf("Invalid number: %v", n)
+		}
+		f, acc := new(big.Float).SetInt(i).Float64()
+		if acc != big.Exact {
+			return nil, fmt.Errorf("Integer can't be held exactly: %v", n)
+		}
+		return f, nil
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return nil, fmt.Errorf("Number out of range: %v", n)
+	}
+	return f, nil
+}
+
+// lispToJSON converts a gigl value into something that encoding/json can
+// marshal, formatting numbers as json.Numbers so that we control how they
+// are written
+func lispToJSON(v lispVal) (interface{}, error) {
+	switch x := v.(type) {
+	case nil, bool, string:
+		return x, nil
+	case float64:
+		n, err := jsonNumber(x)
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(n), nil
+	case KEYWORD:
+		return string(x), nil
+	case SYMBOL:
+		return string(x), nil
+	case CHAR:
+		return string(x), nil
+	case *LispList:
+		return lispSliceToJSON(x.toSlice())
+	case *LazySeq:
+		vals, err := x.toSlice()
+		if err != nil {
+			return nil, err
+		}
+		return lispSliceToJSON(vals)
+	case []lispVal:
+		return lispSliceToJSON(x)
+	case VECTOR:
+		return lispSliceToJSON(x)
+	case SET:
+		vals := make([]lispVal, 0, len(x))
+		for k := range x {
+			vals = append(vals, k)
+		}
+		// Sets have no order so sort them to make the output stable
+		sort.Slice(vals, func(i, j int) bool { return String(vals[i]) < String(vals[j]) })
+		return lispSliceToJSON(vals)
+	case MAP:
+		m := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			key, err := jsonKey(k)
+			if err != nil {
+				return nil, err
+			}
+			if m[key], err = lispToJSON(item); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("Unable to write as JSON: %v", String(v))
+	}
+}
+
+func lispSliceToJSON(items []lispVal) ([]interface{}, error) {
+	vals := make([]interface{}, len(items))
+	for i, item := range items {
+		v, err := lispToJSON(item)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// jsonKey converts a map key into a JSON object key
+func jsonKey(k lispVal) (string, error) {
+	switch k := k.(type) {
+	case string:
+		return k, nil
+	case KEYWORD:
+		return string(k), nil
+	case SYMBOL:
+		return string(k), nil
+	case CHAR:
+		return string(k), nil
+	case float64:
+		return jsonNumber(k)
+	default:
+		return "", fmt.Errorf("Unable to use as a JSON object key: %v", String(k))
+	}
+}
+
+// jsonNumber formats a number without an exponent where we can
+func jsonNumber(f float64) (string, error) {
+	switch {
+	case math.IsNaN(f) || math.IsInf(f, 0):
+		return "", fmt.Errorf("Unable to write as JSON: %v", String(f))
+	case f == math.Trunc(f) && math.Abs(f) < 1e21:
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	}
+}
+
+// jsonError adds the line and column to an error from parsing src
+func jsonError(err error, src string) error {
+	var syntax *json.SyntaxError
+	switch {
+	case errors.As(err, &syntax):
+		// The offset is just after the character that caused the error
+		pos := Pos{Offset: 0, Line: 1, Col: 1}.advance(src[:max(syntax.Offset-1, 0)])
+		return fmt.Errorf("Invalid JSON: %v at %s", err, pos)
+	case err == io.ErrUnexpectedEOF:
+		return fmt.Errorf("Invalid JSON: unexpected end of input")
+	default:
+		return err
+	}
+}
+
+// parse a JSON string: (json-parse s [:keys :keyword])
+func jsonParse(lst ...lispVal) (lispVal, error) {
+	opts, err := keywordArgs("json-parse", lst, 1, "keys")
+	if err != nil {
+		return nil, err
+	}
+	src, err := getString(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	o, err := getJSONOptions("json-parse", opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(strings.NewReader(src))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("Nothing to read in: %v", src)
+		}
+		return nil, jsonError(err, src)
+	}
+	end := int(dec.InputOffset())
+	if rest := strings.TrimLeft(src[end:], " \t\r\n"); rest != "" {
+		pos := Pos{Offset: 0, Line: 1, Col: 1}.advance(src[:len(src)-len(rest)])
+		return nil, fmt.Errorf("Invalid JSON: unexpected data after value at %s", pos)
+	}
+	return jsonToLisp(v, o)
+}
+
+// write a value as a JSON string: (json-stringify x [:pretty #t] [:indent 4])
+func jsonStringify(lst ...lispVal) (lispVal, error) {
+	opts, err := keywordArgs("json-stringify", lst, 1, "pretty", "indent")
+	if err != nil {
+		return nil, err
+	}
+	o, err := getJSONOptions("json-stringify", opts)
+	if err != nil {
+		return nil, err
+	}
+	v, err := lispToJSON(lst[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", o.indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// a lazy sequence of the values in newline delimited JSON:
+// (json-seq [string-or-port] [:keys :keyword])
+// Reading from a port consumes the rest of its input.
+func (e *Evaluator) jsonSeq(lst ...lispVal) (lispVal, error) {
+	// Options come in pairs so an odd number of arguments means that the
+	// source was given
+	nargs := len(lst) % 2
+	opts, err := keywordArgs("json-seq", lst, nargs, "keys")
+	if err != nil {
+		return nil, err
+	}
+	o, err := getJSONOptions("json-seq", opts)
+	if err != nil {
+		return nil, err
+	}
+	lst = lst[:nargs]
+
+	var r io.Reader
+	if len(lst) == 1 {
+		if s, ok := lst[0].(string); ok {
+			r = strings.NewReader(s)
+		}
+	}
+	if r == nil {
+		port, err := e.inputPortArg("json-seq", lst)
+		if err != nil {
+			return nil, err
+		}
+		r = port
+	}
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	record := 0
+	return NewLazySeq(func() (lispVal, bool, error) {
+		record++
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			if err == io.EOF {
+				return nil, false, nil
+			}
+			return nil, false, fmt.Errorf("Invalid JSON in record %d: %v", record, err)
+		}
+		val, err := jsonToLisp(v, o)
+		return val, err == nil, err
+	}), nil
+}
@@ -0,0 +1,67 @@
+package gigl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuiltinOptions(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`(json-parse "{\"a\": 1}")`, `{"a" 1}`},
+		{`(json-parse "{\"a\": 1}" :keys :keyword)`, `{:a 1}`},
+		{`(json-stringify {:a [1 2]})`, `"{\"a\":[1,2]}"`},
+		{`(json-stringify [1] :pretty #t)`, `"[\n  1\n]"`},
+		{`(json-stringify [1] :indent 4)`, `"[\n    1\n]"`},
+		{`(json-seq "{\"a\": 1}\n{\"a\": 2}" :keys :keyword)`, `({:a 1} {:a 2})`},
+		{`(json-parse "[9007199254740992, -9007199254740993.0, 1e300, 0.5]")`, `[9.007199254740992e+15 -9.007199254740992e+15 1e+300 0.5]`},
+		{`(json-parse "18446744073709551616")`, `1.8446744073709552e+19`},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		got, err := evalSource(e, tt.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if String(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.src, String(got), tt.want)
+		}
+	}
+}
+
+func TestUnknownBuiltinOptions(t *testing.T) {
+	for _, src := range []string{
+		`(json-parse "{\"a\": 1}" :keywords #t)`,
+		`(json-parse "{\"a\": 1}" {:keys :keyword})`,
+		`(json-parse "{\"a\": 1}" :keys)`,
+		`(json-stringify 1 :prety #t)`,
+		`(json-seq "1" :key :keyword)`,
+	} {
+		e := newTestEvaluator(t)
+		_, err := evalSource(e, src)
+		if err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
+
+// Integers that a float64 can't hold exactly are an error rather than being
+// silently rounded
+func TestJSONIntegerPrecision(t *testing.T) {
+	for _, src := range []string{
+		`(json-parse "9007199254740993")`,
+		`(json-parse "[1, -9007199254740993]")`,
+		`(json-parse "{\"id\": 12345678901234567890}")`,
+		`(len (json-seq "1 9007199254740993"))`,
+	} {
+		e := newTestEvaluator(t)
+		_, err := evalSource(e, src)
+		if err == nil || !strings.Contains(err.Error(), "can't be held exactly") {
+			t.Errorf("%s: expected a precision error, got %v", src, err)
+		}
+	}
+}
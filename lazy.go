@@ -0,0 +1,101 @@
+package gigl
+
+import "fmt"
+
+/*
+	Lazy sequences
+
+	A LazySeq is a chain of cells that are only computed when something
+	asks for them. Every cell shares the same generator function, which is
+	called at most once per cell, so a realised cell always gives back the
+	same value. car, cdr, null? and friends all understand lazy sequences
+	so the list functions in the prelude work on them unchanged.
+*/
+
+// A LazySeq is a sequence whose elements are computed on demand
+type LazySeq struct {
+	gen      func() (lispVal, bool, error) // returns false once exhausted
+	realized bool
+	empty    bool
+	head     lispVal
+	tail     *LazySeq
+	err      error
+}
+
+// NewLazySeq builds a lazy sequence from a generator function. The
+// generator should return false once there are no more elements.
+func NewLazySeq(gen func() (lispVal, bool, error)) *LazySeq {
+	return &LazySeq{gen: gen}
+}
+
+// realize computes the value of this cell if it hasn't been computed yet
+func (s *LazySeq) realize() error {
+	if s.realized {
+		return s.err
+	}
+	s.realized = true
+
+	val, ok, err := s.gen()
+	switch {
+	case err != nil:
+		s.err = err
+	case !ok:
+		s.empty = true
+	default:
+		s.head = val
+		s.tail = &LazySeq{gen: s.gen}
+	}
+	// Drop the generator so that exhausted sequences can be collected
+	s.gen = nil
+	return s.err
+}
+
+// IsEmpty reports whether there are no more elements in the sequence
+func (s *LazySeq) IsEmpty() (bool, error) {
+	if err := s.realize(); err != nil {
+		return false, err
+	}
+	return s.empty, nil
+}
+
+// First returns the first element of the sequence or nil if it is empty
+func (s *LazySeq) First() (lispVal, error) {
+	if err := s.realize(); err != nil {
+		return nil, err
+	}
+	return s.head, nil
+}
+
+// Rest returns the sequence without its first element
+func (s *LazySeq) Rest() (*LazySeq, error) {
+	if err := s.realize(); err != nil {
+		return nil, err
+	}
+	if s.empty {
+		return s, nil
+	}
+	return s.tail, nil
+}
+
+// toSlice realizes the entire sequence
+// NOTE :: This will never return for an infinite sequence!
+func (s *LazySeq) toSlice() ([]lispVal, error) {
+	vals := make([]lispVal, 0)
+	for cell := s; ; cell = cell.tail {
+		if err := cell.realize(); err != nil {
+			return nil, err
+		}
+		if cell.empty {
+			return vals, nil
+		}
+		vals = append(vals, cell.head)
+	}
+}
+
+func (s *LazySeq) String() string {
+	vals, err := s.toSlice()
+	if err != nil {
+		return fmt.Sprintf("#<lazy-seq error: %v>", err)
+	}
+	return List(vals...).String()
+}
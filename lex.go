@@ -0,0 +1,325 @@
+package gigl
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+	The lexer
+
+	Tokens are recognised in a single pass over the input, dispatching on
+	the first character of each token. Symbols, keywords and numbers are
+	all runs of characters up to the next delimiter, which means that every
+	token (apart from the end of a broken string or comment) is at least one
+	character long and the lexer always makes progress.
+
+	Anything that can't start a token, and any run that starts like a
+	number but isn't one, becomes an ERROR token which the parser reports.
+*/
+
+// Tokenise splits an input string into tokens for parsing
+func (t *Tokeniser) Tokenise(s string) {
+	t.tokens = make([]token, 0, len(s)/4)
+	t.ix = 0
+	t.exhausted = false
+	t.input = s
+	pos := Pos{Offset: 0, Line: 1, Col: 1}
+
+	for pos.Offset < len(s) {
+		tag, end := t.lexToken(s, pos.Offset)
+		text := s[pos.Offset:end]
+		next := pos.advance(text)
+		if t.keepTrivia || !isTrivia(tag) {
+			t.tokens = append(t.tokens, token{Tag: tag, Text: text, Span: Span{pos, next}})
+		}
+		pos = next
+	}
+}
+
+// lexToken returns the tag and end offset of the token starting at s[i]
+func (t *Tokeniser) lexToken(s string, i int) (string, int) {
+	switch s[i] {
+	case '(':
+		return "LIST_START", i + 1
+	case ')':
+		return "LIST_END", i + 1
+	case '[':
+		return "VEC_START", i + 1
+	case ']':
+		return "VEC_END", i + 1
+	case '{':
+		return "MAP_START", i + 1
+	case '}':
+		return "MAP_OR_SET_END", i + 1
+	case ',':
+		return "COMMA", i + 1
+	case '\n':
+		return "NEWLINE", i + 1
+	case '\'', '`':
+		return "QUOTE", i + 1
+
+	case '~':
+		if strings.HasPrefix(s[i:], "~@") {
+			return "SPLICE", i + 2
+		}
+		return "QUOTE", i + 1
+
+	case ';':
+		// The comment runs to the end of the line
+		if end := strings.IndexByte(s[i:], '\n'); end >= 0 {
+			return "COMMENT", i + end
+		}
+		return "COMMENT", len(s)
+
+	case '"':
+		return t.lexString("STRING", s, i+1)
+
+	case ':':
+		end := scanAtom(s, i+1)
+		if end == i+1 {
+			return "ERROR", end
+		}
+		return "KEYWORD", end
+
+	case '#':
+		return t.lexDispatch(s, i)
+	}
+
+	r, size := decodeRune(s, i)
+	switch {
+	case unicode.IsSpace(r):
+		end := i + size
+		for end < len(s) {
+			r, size := decodeRune(s, end)
+			if r == '\n' || !unicode.IsSpace(r) {
+				break
+			}
+			end += size
+		}
+		return "WHITESPACE", end
+
+	case isDelimiter(r):
+		return "ERROR", i + size
+	}
+
+	end := scanAtom(s, i)
+	if isDigit(s[i]) || (s[i] == '-' && i+1 < len(s) && isDigit(s[i+1])) {
+		if tag := numberTag(s[i:end]); tag != "" {
+			return tag, end
+		}
+		return "ERROR", end
+	}
+	return "SYMBOL", end
+}
+
+// lexDispatch handles the tokens that start with #
+func (t *Tokeniser) lexDispatch(s string, i int) (string, int) {
+	if i+1 >= len(s) {
+		return "SYMBOL", i + 1
+	}
+
+	switch s[i+1] {
+	case '{':
+		return "SET_START", i + 2
+	case '"':
+		return t.lexString("REGEX", s, i+2)
+	case '\\':
+		if i+2 == len(s) {
+			t.exhausted = true
+			return "ERROR", len(s)
+		}
+		return "CHAR", scanChar(s, i+2)
+	case '_':
+		return "DATUM_COMMENT", i + 2
+	case '+', '-':
+		return "FEATURE_COND", i + 2
+	case '?':
+		return "READER_COND", i + 2
+
+	case '|':
+		end, closed := blockCommentEnd(s[i:])
+		if !closed {
+			t.exhausted = true
+			return "ERROR", i + end
+		}
+		return "BLOCK_COMMENT", i + end
+
+	case '#':
+		end := scanAtom(s, i+2)
+		switch s[i:end] {
+		case "##NaN", "##Inf", "##-Inf":
+			return "SPECIAL_FLOAT", end
+		}
+		return "ERROR", end
+	}
+
+	end := scanAtom(s, i+1)
+	if text := s[i:end]; text == "#t" || text == "#f" {
+		return "BOOL", end
+	}
+	return "SYMBOL", end
+}
+
+// lexString finds the end of a string whose contents start at s[i]. If the
+// string is never closed then the input ran out part way through a token.
+func (t *Tokeniser) lexString(tag, s string, i int) (string, int) {
+	for i < len(s) {
+		switch s[i] {
+		case '\\':
+			i += 2
+		case '"':
+			return tag, i + 1
+		default:
+			i++
+		}
+	}
+	t.exhausted = true
+	return "ERROR", len(s)
+}
+
+// blockCommentEnd finds the end of a #| ... |# comment at the start of s.
+// Block comments nest and an unterminated one runs to the end of the input,
+// where it becomes an ERROR token.
+func blockCommentEnd(s string) (int, bool) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "#|"):
+			depth++
+			i++
+		case strings.HasPrefix(s[i:], "|#"):
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1, true
+			}
+		}
+	}
+	return len(s), false
+}
+
+// scanChar finds the end of a character literal whose name starts at s[i]:
+// #\a, #\newline or #\x3bb
+func scanChar(s string, i int) int {
+	switch {
+	case s[i] == 'x' && i+1 < len(s) && isHexDigit(s[i+1]):
+		return scanWhile(s, i+1, isHexDigit)
+	case isLetter(s[i]):
+		return scanWhile(s, i, isLetter)
+	default:
+		_, size := decodeRune(s, i)
+		return i + size
+	}
+}
+
+// numberTag classifies the text of a number, returning "" if it isn't one:
+//
+//	INT            -?\d+
+//	FLOAT          -?\d+(\.\d+([eE][+-]?\d+)?|[eE][+-]?\d+)
+//	COMPLEX_PURE   -?\d+\.?\d*j
+//	COMPLEX        -?\d+\.?\d*[+-]\d+\.?\d*j
+func numberTag(s string) string {
+	i := 0
+	if s[0] == '-' {
+		i++
+	}
+	i = scanWhile(s, i, isDigit)
+	if i == len(s) {
+		return "INT"
+	}
+
+	switch s[i] {
+	case '.':
+		if j := scanWhile(s, i+1, isDigit); j > i+1 && (j == len(s) || scanExponent(s, j) == len(s)) {
+			return "FLOAT"
+		}
+	case 'e', 'E':
+		if scanExponent(s, i) == len(s) {
+			return "FLOAT"
+		}
+	}
+
+	// -?\d+\.?\d* is the real part of a complex number
+	if s[i] == '.' {
+		i = scanWhile(s, i+1, isDigit)
+	}
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		j := scanWhile(s, i+1, isDigit)
+		if j == i+1 {
+			return ""
+		}
+		if j < len(s) && s[j] == '.' {
+			j = scanWhile(s, j+1, isDigit)
+		}
+		if j == len(s)-1 && s[j] == 'j' {
+			return "COMPLEX"
+		}
+		return ""
+	}
+	if i == len(s)-1 && s[i] == 'j' {
+		return "COMPLEX_PURE"
+	}
+	return ""
+}
+
+// scanExponent returns the end of an exponent such as e10 or E-3 starting at
+// s[i], or -1 if there isn't one
+func scanExponent(s string, i int) int {
+	if i >= len(s) || (s[i] != 'e' && s[i] != 'E') {
+		return -1
+	}
+	i++
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	if j := scanWhile(s, i, isDigit); j > i {
+		return j
+	}
+	return -1
+}
+
+// isDelimiter reports whether r ends a symbol, keyword or number
+func isDelimiter(r rune) bool {
+	switch r {
+	case '(', ')', '[', ']', '{', '}', ',', '\'', '`', ';', '"', '@':
+		return true
+	}
+	return unicode.IsSpace(r)
+}
+
+// scanAtom returns the end of the run of characters up to the next delimiter
+func scanAtom(s string, i int) int {
+	for i < len(s) {
+		r, size := decodeRune(s, i)
+		if isDelimiter(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// scanWhile returns the end of the run of bytes matching pred
+func scanWhile(s string, i int, pred func(byte) bool) int {
+	for i < len(s) && pred(s[i]) {
+		i++
+	}
+	return i
+}
+
+// decodeRune is utf8.DecodeRuneInString with a fast path for ASCII
+func decodeRune(s string, i int) (rune, int) {
+	if c := s[i]; c < utf8.RuneSelf {
+		return rune(c), 1
+	}
+	return utf8.DecodeRuneInString(s[i:])
+}
+
+func isDigit(c byte) bool  { return '0' <= c && c <= '9' }
+func isLetter(c byte) bool { return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') }
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
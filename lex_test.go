@@ -0,0 +1,103 @@
+package gigl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lexerSeeds are inputs that exercise each kind of token along with the
+// ways that they can be cut short
+var lexerSeeds = []string{
+	"",
+	"(defn f (x) (* x 2))",
+	`"a \"string\" with \n escapes"`,
+	`"unterminated`,
+	`"ends in a backslash\`,
+	`#"re\d+"`,
+	`#"unterminated`,
+	`#\a #\space #\newline #\λ #\`,
+	"#| block #| nested |# |# x",
+	"#| unterminated",
+	"#_ (ignored) kept",
+	"#+gigl x #-gigl y #?(:gigl a :default b)",
+	"{:a 1, :b [1 2 3]} #{:x :y}",
+	"'a `(b ~c ~@d)",
+	"1 -2 3.5 1e10 ##NaN ##Inf ##-Inf 1x2 -",
+	"; comment\n; comment without a newline",
+	"λ →∀  \t\r\n",
+	")(][}{",
+	"#",
+	"\xff\xfe invalid utf-8",
+}
+
+// FuzzTokenise checks that the lexer terminates on any input without
+// panicking and that every token covers a part of the input, in order and
+// with nothing left out.
+func FuzzTokenise(f *testing.F) {
+	for _, seed := range lexerSeeds {
+		f.Add(seed)
+	}
+	for _, src := range prelude {
+		f.Add(src)
+	}
+	if src, err := os.ReadFile(filepath.Join("examples", "examples.ggl")); err == nil {
+		f.Add(string(src))
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		tk := NewTokeniser()
+		tk.keepTrivia = true
+		tk.Tokenise(s)
+
+		offset := 0
+		for _, tok := range tk.tokens {
+			start, end := tok.Span.Start.Offset, tok.Span.End.Offset
+			if start != offset {
+				t.Fatalf("token %q starts at %d, expected %d", tok.Text, start, offset)
+			}
+			if end <= start || end > len(s) {
+				t.Fatalf("token %q has span %d-%d in %d bytes of input", tok.Text, start, end, len(s))
+			}
+			if s[start:end] != tok.Text {
+				t.Fatalf("token text %q doesn't match the input %q", tok.Text, s[start:end])
+			}
+			if tok.Span.Start.advance(tok.Text) != tok.Span.End {
+				t.Fatalf("token %q has inconsistent positions %v-%v", tok.Text, tok.Span.Start, tok.Span.End)
+			}
+			offset = end
+		}
+		if offset != len(s) {
+			t.Fatalf("tokens cover %d of %d bytes", offset, len(s))
+		}
+
+		// The reader must cope with whatever the lexer produced
+		NewTokeniser().ReadAll(s)
+	})
+}
+
+func BenchmarkTokenise(b *testing.B) {
+	examples, err := os.ReadFile(filepath.Join("examples", "examples.ggl"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	inputs := []struct {
+		name string
+		src  string
+	}{
+		{"examples", string(examples)},
+		{"prelude", strings.Join(prelude, "\n")},
+	}
+
+	for _, input := range inputs {
+		b.Run(input.name, func(b *testing.B) {
+			tk := NewTokeniser()
+			b.SetBytes(int64(len(input.src)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tk.Tokenise(input.src)
+			}
+		})
+	}
+}
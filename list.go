@@ -1,7 +1,5 @@
 package gigl
 
-import "strings"
-
 /*
 	To get slicing to work we need this:
 
@@ -68,13 +66,7 @@ func (l *LispList) toSlice() []lispVal {
 }
 
 func (l LispList) String() string {
-	lst := make([]string, l.length)
-	pair := l.root
-	for i := 0; i < l.length; i++ {
-		lst[i] = String(pair.Value)
-		pair = pair.next
-	}
-	return "(" + strings.Join(lst, " ") + ")"
+	return String(&l)
 }
 
 // Len returns the length of a list
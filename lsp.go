@@ -0,0 +1,593 @@
+package gigl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+	Language server
+
+	ServeLSP speaks the Language Server Protocol over a pair of streams
+	(stdin and stdout for `gigl lsp`). Open documents are read with the
+	Tokeniser every time they change:
+
+	  diagnostics      the syntax errors found by ReadAll
+	  definition       where a define, defn or defmacro in an open document
+	                   bound the symbol under the cursor
+	  hover            the arglists and docstring of the symbol
+	  completion       the builtins, prelude, special forms and the names
+	                   defined in open documents
+	  documentSymbol   the top level definitions in a document
+
+	Documents are never evaluated: builtins and the prelude are described
+	by an Evaluator that only has the prelude loaded, while the definitions
+	in documents come from their concrete syntax trees.
+
+	LSP positions count lines from 0 and columns in UTF-16 code units, so
+	they are converted to and from offsets using the text of the document.
+*/
+
+type lspServer struct {
+	e        *Evaluator
+	t        *Tokeniser
+	w        io.Writer
+	docs     map[string]*lspDocument
+	shutdown bool
+}
+
+// lspDocument is an open document along with what was read from it
+type lspDocument struct {
+	uri  string
+	text string
+	root *CSTNode // nil if nothing could be read
+	defs []lspDefinition
+}
+
+// lspDefinition is a top level define, defn or defmacro form
+type lspDefinition struct {
+	name     string
+	form     SYMBOL  // define, defn or defmacro
+	params   lispVal // the parameters if this is a procedure or macro
+	doc      string
+	span     Span // the whole form
+	nameSpan Span
+}
+
+// Messages from the client. Params are decoded by each handler.
+type lspRequest struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+type lspNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextDocumentPosition struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspMarkup struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type lspCompletionItem struct {
+	Label         string     `json:"label"`
+	Kind          int        `json:"kind"`
+	Detail        string     `json:"detail,omitempty"`
+	Documentation *lspMarkup `json:"documentation,omitempty"`
+}
+
+type lspDocumentSymbol struct {
+	Name           string   `json:"name"`
+	Detail         string   `json:"detail,omitempty"`
+	Kind           int      `json:"kind"`
+	Range          lspRange `json:"range"`
+	SelectionRange lspRange `json:"selectionRange"`
+}
+
+// Error codes, completion item kinds and symbol kinds from the LSP spec
+const (
+	lspMethodNotFound = -32601
+	lspInvalidParams  = -32602
+
+	lspCompletionFunction = 3
+	lspCompletionVariable = 6
+	lspCompletionKeyword  = 14
+
+	lspSymbolFunction = 12
+	lspSymbolVariable = 13
+)
+
+// ServeLSP runs a language server that reads requests from r and writes
+// responses to w until the client sends exit or r is closed
+func ServeLSP(r io.Reader, w io.Writer) error {
+	e := NewEvaluator()
+	e.SetOutput(io.Discard)
+	loadPrelude(e)
+	s := &lspServer{e: e, t: NewTokeniser(), w: w, docs: make(map[string]*lspDocument)}
+
+	tp := textproto.NewReader(bufio.NewReader(r))
+	for {
+		req, err := readLSPMessage(tp)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("lsp: exit without shutdown")
+			}
+			return nil
+		}
+
+		result, lerr := s.handle(req)
+		if req.ID == nil {
+			// Notifications don't get a response
+			continue
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if lerr != nil {
+			resp["error"] = lerr
+		} else {
+			resp["result"] = result
+		}
+		if err := s.send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// readLSPMessage reads a message framed with a Content-Length header
+func readLSPMessage(tp *textproto.Reader) (*lspRequest, error) {
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("lsp: invalid Content-Length: %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(tp.R, body); err != nil {
+		return nil, err
+	}
+	var req lspRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("lsp: %v", err)
+	}
+	return &req, nil
+}
+
+func (s *lspServer) send(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+// handle runs a request or notification, returning the result for
+// requests
+func (s *lspServer) handle(req *lspRequest) (interface{}, *lspError) {
+	var pos lspTextDocumentPosition
+	switch req.Method {
+	case "textDocument/definition", "textDocument/hover", "textDocument/completion":
+		if err := json.Unmarshal(req.Params, &pos); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+	}
+
+	switch req.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       1, // the full text is sent on every change
+				"definitionProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+				"completionProvider":     map[string]interface{}{},
+			},
+			"serverInfo": map[string]interface{}{"name": "gigl"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+		return nil, nil
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err == nil && len(params.ContentChanges) > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err == nil {
+			delete(s.docs, params.TextDocument.URI)
+			s.publishDiagnostics(params.TextDocument.URI, nil)
+		}
+		return nil, nil
+
+	case "textDocument/definition":
+		return s.definition(pos), nil
+	case "textDocument/hover":
+		return s.hover(pos), nil
+	case "textDocument/completion":
+		return s.completion(), nil
+	case "textDocument/documentSymbol":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+		return s.documentSymbols(params.TextDocument.URI), nil
+	}
+
+	if req.ID != nil {
+		return nil, &lspError{lspMethodNotFound, "Unknown method: " + req.Method}
+	}
+	return nil, nil
+}
+
+// update reads a document that has been opened or changed and publishes
+// its diagnostics
+func (s *lspServer) update(uri, text string) {
+	doc := &lspDocument{uri: uri, text: text, root: s.readCST(text)}
+	if doc.root != nil {
+		doc.defs = findDefinitions(doc.root)
+	}
+	s.docs[uri] = doc
+
+	var diagnostics []lspDiagnostic
+	if _, err := s.t.ReadAll(text); err != nil {
+		var errs SyntaxErrors
+		switch err := err.(type) {
+		case SyntaxErrors:
+			errs = err
+		case *SyntaxError:
+			errs = SyntaxErrors{err}
+		}
+		for _, e := range errs {
+			diagnostics = append(diagnostics, lspDiagnostic{
+				Range:    doc.lspRange(e.Span),
+				Severity: 1,
+				Source:   "gigl",
+				Message:  e.Message,
+			})
+		}
+	}
+	s.publishDiagnostics(uri, diagnostics)
+}
+
+// readCST reads as much of a document as it can. Documents are usually
+// being edited so if there is a syntax error then the text before it is
+// read instead.
+func (s *lspServer) readCST(text string) *CSTNode {
+	for {
+		root, err := s.t.ReadCST(text)
+		if err == nil {
+			return root
+		}
+		serr, ok := err.(*SyntaxError)
+		if !ok || serr.Span.Start.Offset >= len(text) {
+			return nil
+		}
+		text = text[:serr.Span.Start.Offset]
+	}
+}
+
+func (s *lspServer) publishDiagnostics(uri string, diagnostics []lspDiagnostic) {
+	if diagnostics == nil {
+		diagnostics = []lspDiagnostic{}
+	}
+	s.send(lspNotification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params:  map[string]interface{}{"uri": uri, "diagnostics": diagnostics},
+	})
+}
+
+// findDefinitions lists the top level definitions in a CST
+func findDefinitions(root *CSTNode) []lspDefinition {
+	var defs []lspDefinition
+	for _, node := range root.Children {
+		if node.Kind != "LIST" || len(node.Children) < 3 {
+			continue
+		}
+		head, name := node.Children[0], node.Children[1]
+		if head.Kind != "SYMBOL" || name.Kind != "SYMBOL" {
+			continue
+		}
+		def := lspDefinition{name: name.Text, form: SYMBOL(head.Text), span: node.Span, nameSpan: name.Span}
+		rest := node.Children[2:]
+
+		switch def.form {
+		case "define":
+			// (define f (lambda (params) body))
+			if value := rest[0]; value.Kind == "LIST" && len(value.Children) > 1 {
+				if lam := value.Children[0]; lam.Kind == "SYMBOL" && (lam.Text == "lambda" || lam.Text == "λ") {
+					def.params, _ = value.Children[1].Lower()
+				}
+			}
+		case "defn", "defmacro":
+			if len(rest) > 2 && rest[0].Kind == "STRING" {
+				if doc, err := rest[0].Lower(); err == nil {
+					def.doc, _ = doc.(string)
+				}
+				rest = rest[1:]
+			}
+			if len(rest) > 2 && rest[0].Kind == "MAP" {
+				rest = rest[1:]
+			}
+			def.params, _ = rest[0].Lower()
+		default:
+			continue
+		}
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// symbolAt finds the symbol at or just before a position in a document
+func (s *lspServer) symbolAt(pos lspTextDocumentPosition) (*lspDocument, string, bool) {
+	doc, ok := s.docs[pos.TextDocument.URI]
+	if !ok || doc.root == nil {
+		return nil, "", false
+	}
+	offset := doc.offset(pos.Position)
+	for _, o := range []int{offset, offset - 1} {
+		if node := doc.root.NodeAt(o); node.Kind == "SYMBOL" && node.Span.Start.Offset <= o {
+			return doc, node.Text, true
+		}
+	}
+	return doc, "", false
+}
+
+// lookup finds the definition of a name, preferring the given document
+func (s *lspServer) lookup(doc *lspDocument, name string) (*lspDocument, *lspDefinition) {
+	docs := []*lspDocument{doc}
+	uris := make([]string, 0, len(s.docs))
+	for uri := range s.docs {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		if uri != doc.uri {
+			docs = append(docs, s.docs[uri])
+		}
+	}
+
+	for _, d := range docs {
+		for i := range d.defs {
+			if d.defs[i].name == name {
+				return d, &d.defs[i]
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (s *lspServer) definition(pos lspTextDocumentPosition) interface{} {
+	doc, name, ok := s.symbolAt(pos)
+	if !ok {
+		return nil
+	}
+	if d, def := s.lookup(doc, name); def != nil {
+		return lspLocation{URI: d.uri, Range: d.lspRange(def.nameSpan)}
+	}
+	return nil
+}
+
+func (s *lspServer) hover(pos lspTextDocumentPosition) interface{} {
+	doc, name, ok := s.symbolAt(pos)
+	if !ok {
+		return nil
+	}
+
+	var usageText, docText string
+	if _, def := s.lookup(doc, name); def != nil {
+		if def.params != nil || def.form != "define" {
+			usageText = usage(SYMBOL(name), def.params)
+		}
+		docText = def.doc
+	} else if info, ok := s.e.symbolInfo(SYMBOL(name)); ok {
+		usageText, _ = info["arglists-str"].(string)
+		docText, _ = info["doc"].(string)
+	} else {
+		return nil
+	}
+
+	if usageText == "" {
+		usageText = name
+	}
+	value := "```gigl\n" + usageText + "\n```"
+	if docText != "" {
+		value += "\n\n" + docText
+	}
+	return map[string]interface{}{"contents": lspMarkup{Kind: "markdown", Value: value}}
+}
+
+func (s *lspServer) completion() interface{} {
+	items := make([]lspCompletionItem, 0)
+	seen := make(map[string]bool)
+	for _, doc := range s.docs {
+		for _, def := range doc.defs {
+			if seen[def.name] {
+				continue
+			}
+			seen[def.name] = true
+			item := lspCompletionItem{Label: def.name, Kind: lspCompletionVariable}
+			if def.params != nil || def.form != "define" {
+				item.Kind = lspCompletionFunction
+				item.Detail = usage(SYMBOL(def.name), def.params)
+			}
+			if def.doc != "" {
+				item.Documentation = &lspMarkup{Kind: "markdown", Value: def.doc}
+			}
+			items = append(items, item)
+		}
+	}
+
+	for _, name := range s.e.allNames(s.e.globalEnv) {
+		if seen[name] {
+			continue
+		}
+		item := lspCompletionItem{Label: name, Kind: lspCompletionVariable}
+		switch s.e.nameKind(SYMBOL(name)) {
+		case "special-form", "macro":
+			item.Kind = lspCompletionKeyword
+		case "function":
+			item.Kind = lspCompletionFunction
+		}
+		if info, ok := s.e.symbolInfo(SYMBOL(name)); ok {
+			item.Detail, _ = info["arglists-str"].(string)
+			if doc, ok := info["doc"].(string); ok {
+				item.Documentation = &lspMarkup{Kind: "markdown", Value: doc}
+			}
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items
+}
+
+func (s *lspServer) documentSymbols(uri string) interface{} {
+	symbols := make([]lspDocumentSymbol, 0)
+	doc, ok := s.docs[uri]
+	if !ok {
+		return symbols
+	}
+	for _, def := range doc.defs {
+		symbol := lspDocumentSymbol{
+			Name:           def.name,
+			Detail:         string(def.form),
+			Kind:           lspSymbolVariable,
+			Range:          doc.lspRange(def.span),
+			SelectionRange: doc.lspRange(def.nameSpan),
+		}
+		if def.params != nil || def.form != "define" {
+			symbol.Kind = lspSymbolFunction
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+// lspPosition converts a byte offset into an LSP position
+func (d *lspDocument) lspPosition(offset int) lspPosition {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	lineStart := strings.LastIndexByte(d.text[:offset], '\n') + 1
+	pos := lspPosition{Line: strings.Count(d.text[:lineStart], "\n")}
+	for _, r := range d.text[lineStart:offset] {
+		pos.Character += utf16Len(r)
+	}
+	return pos
+}
+
+func (d *lspDocument) lspRange(span Span) lspRange {
+	return lspRange{d.lspPosition(span.Start.Offset), d.lspPosition(span.End.Offset)}
+}
+
+// offset converts an LSP position into a byte offset
+func (d *lspDocument) offset(pos lspPosition) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		next := strings.IndexByte(d.text[offset:], '\n')
+		if next < 0 {
+			return len(d.text)
+		}
+		offset += next + 1
+	}
+	for units := 0; units < pos.Character && offset < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		units += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+// utf16Len is the number of UTF-16 code units needed for a rune
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,431 @@
+package gigl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lspClient drives ServeLSP over a pair of pipes, holding on to the
+// notifications that arrive while it waits for a response
+type lspClient struct {
+	t             *testing.T
+	w             *io.PipeWriter
+	r             *textproto.Reader
+	done          chan error
+	nextID        int
+	notifications []lspMessage
+}
+
+// lspMessage is a message from the server with the parts that the tests
+// look at
+type lspMessage struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *lspError       `json:"error"`
+}
+
+func startLSP(t *testing.T) *lspClient {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &lspClient{
+		t:    t,
+		w:    inW,
+		r:    textproto.NewReader(bufio.NewReader(outR)),
+		done: make(chan error, 1),
+	}
+	go func() {
+		err := ServeLSP(inR, outW)
+		outW.Close()
+		c.done <- err
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		outR.Close()
+	})
+	return c
+}
+
+func (c *lspClient) send(msg map[string]interface{}) {
+	c.t.Helper()
+	msg["jsonrpc"] = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read returns the next message from the server
+func (c *lspClient) read() lspMessage {
+	c.t.Helper()
+	type result struct {
+		msg lspMessage
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		header, err := c.r.ReadMIMEHeader()
+		if err != nil {
+			ch <- result{err: err}
+			return
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			ch <- result{err: err}
+			return
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(c.r.R, body); err != nil {
+			ch <- result{err: err}
+			return
+		}
+		var msg lspMessage
+		err = json.Unmarshal(body, &msg)
+		ch <- result{msg, err}
+	}()
+
+	select {
+	case res := <-ch:
+		if res.err != nil {
+			c.t.Fatalf("reading from the server: %v", res.err)
+		}
+		return res.msg
+	case <-time.After(10 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return lspMessage{}
+}
+
+// request sends a request and decodes the result of its response into
+// result, returning any error the server gave
+func (c *lspClient) request(method string, params interface{}, result interface{}) *lspError {
+	c.t.Helper()
+	c.nextID++
+	id := c.nextID
+	c.send(map[string]interface{}{"id": id, "method": method, "params": params})
+	for {
+		msg := c.read()
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		if *msg.ID != id {
+			c.t.Fatalf("%s: response to request %d", method, *msg.ID)
+		}
+		if msg.Error == nil && result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("%s: decoding %s: %v", method, msg.Result, err)
+			}
+		}
+		return msg.Error
+	}
+}
+
+func (c *lspClient) notify(method string, params interface{}) {
+	c.t.Helper()
+	c.send(map[string]interface{}{"method": method, "params": params})
+}
+
+// diagnostics waits for the diagnostics published for a document
+func (c *lspClient) diagnostics(uri string) []lspDiagnostic {
+	c.t.Helper()
+	for {
+		msg := c.read()
+		if msg.Method != "textDocument/publishDiagnostics" {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		var params struct {
+			URI         string          `json:"uri"`
+			Diagnostics []lspDiagnostic `json:"diagnostics"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			c.t.Fatal(err)
+		}
+		if params.URI != uri {
+			c.t.Fatalf("diagnostics for %s, want %s", params.URI, uri)
+		}
+		return params.Diagnostics
+	}
+}
+
+func (c *lspClient) open(uri, text string) []lspDiagnostic {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "gigl", "version": 1, "text": text},
+	})
+	return c.diagnostics(uri)
+}
+
+func docPosition(uri string, line, char int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": char},
+	}
+}
+
+// shutdown ends the session cleanly and waits for ServeLSP to return
+func (c *lspClient) shutdown() {
+	c.t.Helper()
+	if err := c.request("shutdown", nil, nil); err != nil {
+		c.t.Fatalf("shutdown: %v", err.Message)
+	}
+	c.notify("exit", nil)
+	select {
+	case err := <-c.done:
+		if err != nil {
+			c.t.Errorf("ServeLSP returned %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		c.t.Fatal("ServeLSP didn't return after exit")
+	}
+}
+
+const lspTestDoc = `(defn twice "Double x." (x) (* x 2))
+(define answer 42)
+(define inc (lambda (n) (+ n 1)))
+(define 😀x 1)
+(twice (inc answer 😀x))
+`
+
+func TestLSP(t *testing.T) {
+	c := startLSP(t)
+
+	var init struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	if err := c.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &init); err != nil {
+		t.Fatalf("initialize: %v", err.Message)
+	}
+	for _, cap := range []string{"definitionProvider", "hoverProvider", "documentSymbolProvider", "completionProvider"} {
+		if init.Capabilities[cap] == nil {
+			t.Errorf("initialize didn't advertise %s", cap)
+		}
+	}
+	c.notify("initialized", map[string]interface{}{})
+
+	const uri = "file:///test.ggl"
+	if diags := c.open(uri, lspTestDoc); len(diags) != 0 {
+		t.Errorf("unexpected diagnostics: %+v", diags)
+	}
+
+	// Definitions, with positions in UTF-16 code units
+	definitions := []struct {
+		line, char int
+		want       *lspRange
+	}{
+		{4, 2, &lspRange{lspPosition{0, 6}, lspPosition{0, 11}}},
+		{4, 6, &lspRange{lspPosition{0, 6}, lspPosition{0, 11}}}, // just after the symbol
+		{4, 9, &lspRange{lspPosition{2, 8}, lspPosition{2, 11}}},
+		{4, 14, &lspRange{lspPosition{1, 8}, lspPosition{1, 14}}},
+		{4, 21, &lspRange{lspPosition{3, 8}, lspPosition{3, 11}}},
+		{0, 30, nil}, // * is a builtin
+		{4, 0, nil},  // an opening bracket
+	}
+	for _, tt := range definitions {
+		var loc *lspLocation
+		if err := c.request("textDocument/definition", docPosition(uri, tt.line, tt.char), &loc); err != nil {
+			t.Fatalf("definition: %v", err.Message)
+		}
+		switch {
+		case tt.want == nil && loc != nil:
+			t.Errorf("%d:%d: unexpected definition %+v", tt.line, tt.char, loc)
+		case tt.want != nil && (loc == nil || loc.URI != uri || loc.Range != *tt.want):
+			t.Errorf("%d:%d: definition was %+v, want %+v", tt.line, tt.char, loc, *tt.want)
+		}
+	}
+
+	hovers := []struct {
+		line, char int
+		want       string
+	}{
+		{4, 2, "```gigl\n(twice x)\n```\n\nDouble x."},
+		{4, 9, "```gigl\n(inc n)\n```"},
+		{4, 14, "```gigl\nanswer\n```"},
+		{0, 30, "```gigl\n(* x y ...)\n```\n\nMultiply two or more numbers in succession."},
+		{4, 0, ""},
+	}
+	for _, tt := range hovers {
+		var hover *struct {
+			Contents lspMarkup `json:"contents"`
+		}
+		if err := c.request("textDocument/hover", docPosition(uri, tt.line, tt.char), &hover); err != nil {
+			t.Fatalf("hover: %v", err.Message)
+		}
+		got := ""
+		if hover != nil {
+			got = hover.Contents.Value
+		}
+		if got != tt.want {
+			t.Errorf("%d:%d: hover was %q, want %q", tt.line, tt.char, got, tt.want)
+		}
+	}
+
+	var items []lspCompletionItem
+	if err := c.request("textDocument/completion", docPosition(uri, 5, 0), &items); err != nil {
+		t.Fatalf("completion: %v", err.Message)
+	}
+	kinds := make(map[string]lspCompletionItem)
+	for _, item := range items {
+		kinds[item.Label] = item
+	}
+	completions := []struct {
+		label  string
+		kind   int
+		detail string
+	}{
+		{"twice", lspCompletionFunction, "(twice x)"},
+		{"inc", lspCompletionFunction, "(inc n)"},
+		{"answer", lspCompletionVariable, ""},
+		{"car", lspCompletionFunction, "(car lst)"},
+		{"map", lspCompletionFunction, "(map f lst)"},
+		{"if", lspCompletionKeyword, "(if test then [else])"},
+	}
+	for _, tt := range completions {
+		item, ok := kinds[tt.label]
+		if !ok || item.Kind != tt.kind || item.Detail != tt.detail {
+			t.Errorf("completion for %s was %+v, want kind %d detail %q", tt.label, item, tt.kind, tt.detail)
+		}
+	}
+	if item := kinds["twice"]; item.Documentation == nil || item.Documentation.Value != "Double x." {
+		t.Errorf("completion for twice has documentation %+v", item.Documentation)
+	}
+
+	var symbols []lspDocumentSymbol
+	params := map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}}
+	if err := c.request("textDocument/documentSymbol", params, &symbols); err != nil {
+		t.Fatalf("documentSymbol: %v", err.Message)
+	}
+	var got []string
+	for _, sym := range symbols {
+		got = append(got, fmt.Sprintf("%s %s %d %d:%d-%d:%d", sym.Name, sym.Detail, sym.Kind,
+			sym.Range.Start.Line, sym.Range.Start.Character, sym.Range.End.Line, sym.Range.End.Character))
+	}
+	want := []string{
+		"twice defn 12 0:0-0:36",
+		"answer define 13 1:0-1:18",
+		"inc define 12 2:0-2:33",
+		"😀x define 13 3:0-3:14",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("document symbols were:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if err := c.request("textDocument/formatting", params, nil); err == nil || err.Code != lspMethodNotFound {
+		t.Errorf("unknown method gave %+v", err)
+	}
+
+	c.notify("textDocument/didClose", params)
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Errorf("closing the document published %+v", diags)
+	}
+	c.shutdown()
+}
+
+// Documents being edited are often incomplete or contain forms that can't
+// be read. They get diagnostics and the definitions before the problem can
+// still be used.
+func TestLSPBrokenDocuments(t *testing.T) {
+	c := startLSP(t)
+	if err := c.request("initialize", map[string]interface{}{}, nil); err != nil {
+		t.Fatalf("initialize: %v", err.Message)
+	}
+
+	tests := []struct {
+		text     string
+		messages []string
+		defs     []string
+	}{
+		{
+			"(define a 1)\n(defn f (x)\n  (+ x a",
+			[]string{"2:2 Unclosed `(` opened", "1:0 Unclosed `(` opened"},
+			[]string{"a"},
+		},
+		{
+			"(define a 1)\n(foo #\"(\" a",
+			[]string{"1:5 Invalid regex #\"(\": error parsing regexp: missing closing ): `(`", "1:0 Unclosed `(` opened"},
+			[]string{"a"},
+		},
+		{"#\"(\"", []string{"0:0 Invalid regex #\"(\": error parsing regexp: missing closing ): `(`"}, nil},
+		{
+			"(define m {#\"(\" 1})\n(defn g (x)",
+			[]string{"0:11 Invalid regex #\"(\": error parsing regexp: missing closing ): `(`", "1:0 Unclosed `(` opened"},
+			nil,
+		},
+		{
+			"#+#\"(\" (define n 1)\n(",
+			[]string{"0:2 Invalid regex #\"(\": error parsing regexp: missing closing ): `(`", "1:0 Unclosed `(` opened"},
+			nil,
+		},
+		{"(define b \"unterminated)", []string{"0:10 Unterminated string", "0:0 Unclosed `(` opened"}, nil},
+		{"(define c 1))\n(define d 2)", []string{"0:12 Unexpected `)`"}, []string{"c"}},
+		{"(", []string{"0:0 Unclosed `(` opened"}, nil},
+	}
+
+	for i, tt := range tests {
+		uri := fmt.Sprintf("file:///broken%d.ggl", i)
+		var messages []string
+		for _, diag := range c.open(uri, tt.text) {
+			messages = append(messages, fmt.Sprintf("%d:%d %s", diag.Range.Start.Line, diag.Range.Start.Character, diag.Message))
+		}
+		if strings.Join(messages, "\n") != strings.Join(tt.messages, "\n") {
+			t.Errorf("%q: diagnostics were %q, want %q", tt.text, messages, tt.messages)
+		}
+
+		var symbols []lspDocumentSymbol
+		params := map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}}
+		if err := c.request("textDocument/documentSymbol", params, &symbols); err != nil {
+			t.Fatalf("documentSymbol: %v", err.Message)
+		}
+		var defs []string
+		for _, sym := range symbols {
+			defs = append(defs, sym.Name)
+		}
+		if strings.Join(defs, " ") != strings.Join(tt.defs, " ") {
+			t.Errorf("%q: symbols were %q, want %q", tt.text, defs, tt.defs)
+		}
+
+		// Nothing that looks at the document falls over
+		for line := 0; line < 3; line++ {
+			for char := 0; char < 12; char++ {
+				c.request("textDocument/hover", docPosition(uri, line, char), nil)
+				c.request("textDocument/definition", docPosition(uri, line, char), nil)
+			}
+		}
+	}
+
+	// Fixing the document clears the diagnostics
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": "file:///broken0.ggl", "version": 2},
+		"contentChanges": []interface{}{map[string]interface{}{"text": "(define a 1)\n(defn f (x)\n  (+ x a))"}},
+	})
+	if diags := c.diagnostics("file:///broken0.ggl"); len(diags) != 0 {
+		t.Errorf("diagnostics after fixing the document: %+v", diags)
+	}
+	c.shutdown()
+}
+
+func TestLSPExitWithoutShutdown(t *testing.T) {
+	c := startLSP(t)
+	c.notify("exit", nil)
+	select {
+	case err := <-c.done:
+		if err == nil {
+			t.Error("expected an error")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("ServeLSP didn't return after exit")
+	}
+}
@@ -0,0 +1,151 @@
+package gigl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+	Metadata
+
+	Metadata is a MAP of information about a binding or a procedure that
+	has no effect on how it behaves. defn and defmacro record:
+
+	  :doc         the docstring, if there is one
+	  :arglists    the parameter list
+	  :source-pos  where the definition was read from, as "name:line:col"
+
+	along with anything in an attribute map following the docstring, such
+	as :since. Builtins get :doc and :arglists from builtinDocs.
+
+	Metadata for bindings lives in the environment alongside the values.
+	Procedures are Go closures, which can't carry anything extra, so those
+	made by defn and with-meta are wrapped in a Procedure that holds the
+	metadata as well. The metadata is then collected along with the
+	procedure once nothing refers to it.
+*/
+
+type procedureFunc = func(...lispVal) (lispVal, error)
+
+// Procedure is a procedure along with its metadata
+type Procedure struct {
+	fn   procedureFunc
+	meta MAP
+}
+
+// asProcedure returns the function behind a procedure value, which is
+// either a bare procedureFunc or a Procedure carrying metadata
+func asProcedure(v lispVal) (procedureFunc, bool) {
+	switch p := v.(type) {
+	case procedureFunc:
+		return p, true
+	case *Procedure:
+		return p.fn, true
+	}
+	return nil, false
+}
+
+// valueMeta returns the metadata attached to a value
+func (e *Evaluator) valueMeta(v lispVal) MAP {
+	if proc, ok := v.(*Procedure); ok {
+		return proc.meta
+	}
+	return nil
+}
+
+// splitSourcePos splits a :source-pos into the file, line and column
+func splitSourcePos(pos string) (string, int, int, bool) {
+	i := strings.LastIndexByte(pos, ':')
+	if i < 0 {
+		return "", 0, 0, false
+	}
+	j := strings.LastIndexByte(pos[:i], ':')
+	if j < 0 {
+		return "", 0, 0, false
+	}
+	line, err1 := strconv.Atoi(pos[j+1 : i])
+	col, err2 := strconv.Atoi(pos[i+1:])
+	if err1 != nil || err2 != nil {
+		return "", 0, 0, false
+	}
+	return pos[:j], line, col, true
+}
+
+// definitionMeta pulls the optional docstring and attribute map off the
+// front of a defn or defmacro form, returning the rest of the form:
+// (defn name "docstring" {:since "0.4"} (params) body)
+func (e *Evaluator) definitionMeta(rest *LispList) (MAP, *LispList) {
+	meta := make(MAP)
+	if doc, ok := rest.Head().(string); ok && rest.Len() > 2 {
+		meta[KEYWORD("doc")] = doc
+		_, rest = rest.popHead()
+	}
+	if attrs, ok := rest.Head().(MAP); ok && rest.Len() > 2 {
+		for k, v := range attrs {
+			meta[k] = v
+		}
+		_, rest = rest.popHead()
+	}
+	if e.sourcePos != "" {
+		meta[KEYWORD("source-pos")] = e.sourcePos
+	}
+	return meta, rest
+}
+
+// documentBuiltins attaches the entries in builtinDocs to the builtins in
+// the global environment
+func (e *Evaluator) documentBuiltins() {
+	t := NewTokeniser()
+	for sym, doc := range builtinDocs {
+		if _, ok := e.globalEnv.vals[sym]; !ok {
+			continue
+		}
+		usage, err := t.read(doc[0])
+		if err != nil {
+			panic(fmt.Sprintf("Invalid usage for builtin %v: %v", sym, err))
+		}
+		e.globalEnv.setMeta(sym, MAP{
+			KEYWORD("doc"):      doc[1],
+			KEYWORD("arglists"): usage.(*LispList).Tail(),
+		})
+	}
+}
+
+// the metadata of a procedure, or of the binding of a quoted symbol:
+// (meta f) or (meta 'f)
+func (e *Evaluator) meta(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("meta takes a single argument")
+	}
+	var meta MAP
+	if sym, ok := lst[0].(SYMBOL); ok {
+		meta = e.globalEnv.lookupMeta(sym)
+		if meta == nil {
+			meta = e.macroMeta[sym]
+		}
+	} else {
+		meta = e.valueMeta(lst[0])
+	}
+	if meta == nil {
+		return nil, nil
+	}
+	return meta, nil
+}
+
+// a copy of a procedure with the given metadata: (with-meta f {:doc "..."})
+func (e *Evaluator) withMeta(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 2 {
+		return nil, fmt.Errorf("with-meta takes a procedure and a map")
+	}
+	proc, ok := asProcedure(lst[0])
+	if !ok {
+		return nil, fmt.Errorf("with-meta: only procedures can have metadata: %v", String(lst[0]))
+	}
+	meta, ok := lst[1].(MAP)
+	if !ok && lst[1] != nil {
+		return nil, fmt.Errorf("with-meta: expected a map: %v", String(lst[1]))
+	}
+
+	return &Procedure{fn: proc, meta: meta}, nil
+}
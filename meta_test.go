@@ -0,0 +1,65 @@
+package gigl
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestProcedureMeta(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`(defn f "Doubles." (x) (* x 2)) (f 4)`, `8`},
+		{`(defn f "Doubles." (x) (* x 2)) (get (meta f) :doc)`, `"Doubles."`},
+		{`(defn f (x) x) (get (meta f) :arglists)`, `(x)`},
+		{`(defn f (x) x) (define g f) (get (meta g) :arglists)`, `(x)`},
+		{`(defn f (x) x) f`, `#<procedure>`},
+		{`(define g (with-meta (lambda (x) (+ x 1)) {:doc "Inc."})) (g 1)`, `2`},
+		{`(define g (with-meta (lambda (x) x) {:doc "Inc."})) (get (meta g) :doc)`, `"Inc."`},
+		{`(defn f "Old." (x) x) (define g (with-meta f {:doc "New."})) (list (get (meta f) :doc) (get (meta g) :doc))`, `("Old." "New.")`},
+		{`(meta (lambda (x) x))`, `nil`},
+		{`(defn up (m) "X") (re-replace #"a" "banana" up)`, `"bXnXnX"`},
+		{`(map (with-meta (lambda (x) (* x x)) {}) '(1 2 3))`, `(1 4 9)`},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		got, err := evalSource(e, tt.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if String(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.src, String(got), tt.want)
+		}
+	}
+}
+
+// Metadata is held by the procedure itself so replacing a procedure in a
+// loop doesn't keep every previous one alive
+func TestProcedureMetaIsCollected(t *testing.T) {
+	e := newTestEvaluator(t)
+	if _, err := evalSource(e, `(defn f (x) x) (define g (with-meta f {}))`); err != nil {
+		t.Fatal(err)
+	}
+	collected := make(chan struct{})
+	runtime.SetFinalizer(e.globalEnv.vals[SYMBOL("g")].(*Procedure), func(*Procedure) {
+		close(collected)
+	})
+
+	src := `(define n 0) (while (< n 100) (set! g (with-meta f {:n n})) (set! n (+ n 1)))`
+	if _, err := evalSource(e, src); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		select {
+		case <-collected:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Error("the first value of g was never collected")
+}
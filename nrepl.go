@@ -0,0 +1,475 @@
+package gigl
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sminez/gigl/bencode"
+)
+
+/*
+	nREPL server
+
+	NREPLServer speaks the bencode based protocol used by nREPL so that
+	editors with an nREPL client can evaluate code in a running gigl. Each
+	session has its own Evaluator with the prelude loaded, so definitions
+	made in one session are invisible to the others. The supported ops are:
+
+	  describe              the ops and versions supported by the server
+	  clone                 create a new session
+	  close                 close a session
+	  ls-sessions           list the open sessions
+	  eval                  evaluate code, replying with each value
+	  load-file             evaluate the contents of a file
+	  interrupt             stop the evaluation in progress
+	  completions           names that start with a prefix ("complete" too)
+	  lookup                the arglists and docstring of a symbol ("info" too)
+
+	Requests without a session use one that belongs to the connection.
+	Requests for a session are handled one at a time in the order they
+	arrive, apart from interrupt which takes effect immediately. Anything
+	written to the current output port while evaluating is sent back to
+	the client as "out" messages for the request.
+*/
+
+// NREPLServer serves nREPL sessions over any number of connections
+type NREPLServer struct {
+	mu       sync.Mutex
+	sessions map[string]*nreplSession
+}
+
+// nreplSession is an Evaluator along with the requests waiting for it
+type nreplSession struct {
+	id  string
+	e   *Evaluator
+	out *sessionWriter
+
+	mu      sync.Mutex
+	queue   []func()
+	busy    bool               // a goroutine is working through the queue
+	running string             // the id of the eval in progress
+	cancel  context.CancelFunc // stops the eval in progress
+}
+
+// sessionWriter sends output to the client of the eval in progress
+type sessionWriter struct {
+	mu   sync.Mutex
+	send func(string)
+}
+
+func (w *sessionWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.send != nil {
+		w.send(string(p))
+	}
+	return len(p), nil
+}
+
+func (w *sessionWriter) redirect(send func(string)) {
+	w.mu.Lock()
+	w.send = send
+	w.mu.Unlock()
+}
+
+// nreplConn is a client connection
+type nreplConn struct {
+	server  *NREPLServer
+	mu      sync.Mutex // guards writes to w
+	w       io.Writer
+	session *nreplSession // used by requests that don't name a session
+}
+
+type nreplMsg = map[string]interface{}
+
+// The ops that the server understands, reported by describe
+var nreplOps = []string{
+	"clone", "close", "complete", "completions", "describe", "eval",
+	"info", "interrupt", "load-file", "lookup", "ls-sessions",
+}
+
+// NewNREPLServer creates a server with no sessions
+func NewNREPLServer() *NREPLServer {
+	return &NREPLServer{sessions: make(map[string]*nreplSession)}
+}
+
+// Serve accepts connections on l until it is closed
+func (s *NREPLServer) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle reads requests from a connection until it is closed
+func (s *NREPLServer) handle(conn net.Conn) {
+	defer conn.Close()
+	c := &nreplConn{server: s, w: conn}
+	defer func() {
+		// Nobody is left to see the results of the connection's session
+		if c.session != nil {
+			c.session.stop()
+		}
+	}()
+	d := bencode.NewDecoder(conn)
+	for {
+		v, err := d.Decode()
+		if err != nil {
+			return
+		}
+		if req, ok := v.(nreplMsg); ok {
+			c.dispatch(req)
+		}
+	}
+}
+
+// newNREPLSession creates a session with a fresh Evaluator
+func newNREPLSession() *nreplSession {
+	e := NewEvaluator()
+	out := &sessionWriter{}
+	e.SetOutput(out)
+	e.SetInput(strings.NewReader(""))
+	loadPrelude(e)
+	return &nreplSession{id: newSessionID(), e: e, out: out}
+}
+
+// newSessionID returns a random UUID
+func newSessionID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// enqueue adds a job to the session, starting a goroutine to run it if
+// there isn't one already
+func (s *nreplSession) enqueue(job func()) {
+	s.mu.Lock()
+	s.queue = append(s.queue, job)
+	start := !s.busy
+	s.busy = true
+	s.mu.Unlock()
+	if start {
+		go s.work()
+	}
+}
+
+func (s *nreplSession) work() {
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.busy = false
+			s.mu.Unlock()
+			return
+		}
+		job := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+		job()
+	}
+}
+
+// send writes a message to the client
+func (c *nreplConn) send(msg nreplMsg) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	bencode.NewEncoder(c.w).Encode(msg)
+}
+
+// reply sends a response to req, tagged with its id and session
+func (c *nreplConn) reply(req nreplMsg, session string, msg nreplMsg) {
+	if id, ok := req["id"].(string); ok {
+		msg["id"] = id
+	}
+	if session != "" {
+		msg["session"] = session
+	}
+	c.send(msg)
+}
+
+func nreplStatus(status ...string) nreplMsg {
+	vals := make([]interface{}, len(status))
+	for i, s := range status {
+		vals[i] = s
+	}
+	return nreplMsg{"status": vals}
+}
+
+// dispatch handles a single request
+func (c *nreplConn) dispatch(req nreplMsg) {
+	op, _ := req["op"].(string)
+	switch op {
+	case "describe":
+		ops := make(nreplMsg)
+		for _, name := range nreplOps {
+			ops[name] = nreplMsg{}
+		}
+		msg := nreplStatus("done")
+		msg["ops"] = ops
+		msg["versions"] = nreplMsg{
+			"nrepl": nreplMsg{"major": 1, "minor": 0, "incremental": 0, "version-string": "1.0.0"},
+		}
+		c.reply(req, "", msg)
+		return
+	case "clone":
+		session := newNREPLSession()
+		c.server.mu.Lock()
+		c.server.sessions[session.id] = session
+		c.server.mu.Unlock()
+		msg := nreplStatus("done")
+		msg["new-session"] = session.id
+		c.reply(req, "", msg)
+		return
+	case "ls-sessions":
+		c.server.mu.Lock()
+		ids := make([]string, 0, len(c.server.sessions))
+		for id := range c.server.sessions {
+			ids = append(ids, id)
+		}
+		c.server.mu.Unlock()
+		sort.Strings(ids)
+		msg := nreplStatus("done")
+		msg["sessions"] = ids
+		c.reply(req, "", msg)
+		return
+	}
+
+	session, ok := c.findSession(req)
+	if !ok {
+		c.reply(req, "", nreplStatus("error", "unknown-session", "done"))
+		return
+	}
+
+	switch op {
+	case "close":
+		c.server.mu.Lock()
+		delete(c.server.sessions, session.id)
+		c.server.mu.Unlock()
+		session.stop()
+		c.reply(req, session.id, nreplStatus("session-closed", "done"))
+	case "interrupt":
+		c.interrupt(req, session)
+	case "eval", "load-file", "completions", "complete", "lookup", "info":
+		session.enqueue(func() { c.run(op, req, session) })
+	default:
+		c.reply(req, session.id, nreplStatus("error", "unknown-op", "done"))
+	}
+}
+
+// findSession returns the session named by a request, or the connection's
+// own session if it doesn't name one
+func (c *nreplConn) findSession(req nreplMsg) (*nreplSession, bool) {
+	if id, ok := req["session"].(string); ok {
+		c.server.mu.Lock()
+		defer c.server.mu.Unlock()
+		session, ok := c.server.sessions[id]
+		return session, ok
+	}
+	if c.session == nil {
+		c.session = newNREPLSession()
+	}
+	return c.session, true
+}
+
+// interrupt stops the eval in progress if it is the one the request names
+func (c *nreplConn) interrupt(req nreplMsg, session *nreplSession) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	id, named := req["interrupt-id"].(string)
+	switch {
+	case session.running == "":
+		c.reply(req, session.id, nreplStatus("session-idle", "done"))
+	case named && id != session.running:
+		c.reply(req, session.id, nreplStatus("error", "interrupt-id-mismatch", "done"))
+	default:
+		session.cancel()
+		c.reply(req, session.id, nreplStatus("done"))
+	}
+}
+
+// stop cancels the eval in progress, if there is one
+func (s *nreplSession) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// run handles a request that needs the session's evaluator
+func (c *nreplConn) run(op string, req nreplMsg, session *nreplSession) {
+	e := session.e
+	switch op {
+	case "eval", "load-file":
+		code, _ := req["code"].(string)
+		name := "nrepl"
+		line := int64(1)
+		if op == "load-file" {
+			code, _ = req["file"].(string)
+			if path, ok := req["file-path"].(string); ok {
+				name = path
+			}
+		} else {
+			if file, ok := req["file"].(string); ok {
+				name = file
+			}
+			if l, ok := req["line"].(int64); ok {
+				line = l
+			}
+		}
+		c.eval(req, session, code, name, line, op == "eval")
+
+	case "completions", "complete":
+		prefix, _ := req["prefix"].(string)
+		candidates := make([]interface{}, 0)
+		for _, name := range e.allNames(e.globalEnv) {
+			if strings.HasPrefix(name, prefix) {
+				candidates = append(candidates, nreplMsg{
+					"candidate": name,
+					"type":      e.nameKind(SYMBOL(name)),
+				})
+			}
+		}
+		msg := nreplStatus("done")
+		msg["completions"] = candidates
+		c.reply(req, session.id, msg)
+
+	case "lookup", "info":
+		sym, _ := req["sym"].(string)
+		info, ok := e.symbolInfo(SYMBOL(sym))
+		if !ok {
+			c.reply(req, session.id, nreplStatus("no-info", "done"))
+			return
+		}
+		msg := nreplStatus("done")
+		if op == "lookup" {
+			msg["info"] = info
+		} else {
+			for k, v := range info {
+				msg[k] = v
+			}
+		}
+		c.reply(req, session.id, msg)
+	}
+}
+
+// eval evaluates code in a session, sending a value for each form if
+// eachValue is set and otherwise only for the last one
+func (c *nreplConn) eval(req nreplMsg, session *nreplSession, code, name string, line int64, eachValue bool) {
+	e := session.e
+	id, _ := req["id"].(string)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	session.mu.Lock()
+	session.running, session.cancel = id, cancel
+	session.mu.Unlock()
+	session.out.redirect(func(text string) {
+		c.reply(req, session.id, nreplMsg{"out": text})
+	})
+
+	defer func() {
+		session.out.redirect(nil)
+		session.mu.Lock()
+		session.running, session.cancel = "", nil
+		session.mu.Unlock()
+		cancel()
+		e.sourcePos = ""
+	}()
+
+	fail := func(err error) {
+		e.setError(err)
+		c.reply(req, session.id, nreplMsg{"err": err.Error() + "\n"})
+		if errors.Is(err, errInterrupted) {
+			c.reply(req, session.id, nreplStatus("interrupted"))
+		} else {
+			msg := nreplStatus("eval-error")
+			msg["ex"], msg["root-ex"] = "error", "error"
+			c.reply(req, session.id, msg)
+		}
+		c.reply(req, session.id, nreplStatus("done"))
+	}
+
+	forms, positions, err := e.reader.readAllPositions(code)
+	if err != nil {
+		fail(err)
+		return
+	}
+	var result lispVal
+	for i, form := range forms {
+		e.sourcePos = fmt.Sprintf("%s:%d:%d", name, int64(positions[i].Line)+line-1, positions[i].Col)
+		if result, err = e.EvalContext(ctx, form); err != nil {
+			fail(err)
+			return
+		}
+		e.pushResult(result)
+		if eachValue {
+			c.reply(req, session.id, nreplMsg{"value": String(result), "ns": "user"})
+		}
+	}
+	if !eachValue {
+		c.reply(req, session.id, nreplMsg{"value": String(result), "ns": "user"})
+	}
+	c.reply(req, session.id, nreplStatus("done"))
+}
+
+// nameKind describes what a name refers to for completion
+func (e *Evaluator) nameKind(sym SYMBOL) string {
+	if _, ok := specialForms[sym]; ok {
+		return "special-form"
+	}
+	if _, ok := e.macroTable[sym]; ok {
+		return "macro"
+	}
+	if _, ok := asProcedure(e.globalEnv.vals[sym]); ok {
+		return "function"
+	}
+	return "var"
+}
+
+// symbolInfo is what an editor shows about a symbol: its name, arglists
+// and docstring
+func (e *Evaluator) symbolInfo(sym SYMBOL) (nreplMsg, bool) {
+	info := nreplMsg{"name": string(sym), "ns": "user"}
+	if form, ok := specialForms[sym]; ok {
+		info["arglists-str"] = form
+		info["special-form"] = "true"
+		return info, true
+	}
+
+	var meta MAP
+	if _, ok := e.macroTable[sym]; ok {
+		meta = e.macroMeta[sym]
+		info["macro"] = "true"
+	} else if frame := e.globalEnv.find(sym); frame != nil {
+		meta = frame.meta[sym]
+		if meta == nil {
+			meta = e.valueMeta(frame.vals[sym])
+		}
+	} else {
+		return nil, false
+	}
+
+	if params, ok := meta[KEYWORD("arglists")]; ok {
+		info["arglists-str"] = usage(sym, params)
+	}
+	if doc, ok := meta[KEYWORD("doc")].(string); ok {
+		info["doc"] = doc
+	}
+	if pos, ok := meta[KEYWORD("source-pos")].(string); ok {
+		if file, line, col, ok := splitSourcePos(pos); ok {
+			info["file"], info["line"], info["column"] = file, line, col
+		}
+	}
+	return info, true
+}
@@ -0,0 +1,281 @@
+package gigl
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sminez/gigl/bencode"
+)
+
+// nreplClient talks to an NREPLServer over loopback, holding on to any
+// responses that arrive for requests other than the one being waited for
+type nreplClient struct {
+	t       *testing.T
+	conn    net.Conn
+	enc     *bencode.Encoder
+	dec     *bencode.Decoder
+	pending map[string][]nreplMsg
+}
+
+func dialNREPL(t *testing.T) *nreplClient {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go NewNREPLServer().Serve(l)
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		l.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		l.Close()
+	})
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	return &nreplClient{
+		t:       t,
+		conn:    conn,
+		enc:     bencode.NewEncoder(conn),
+		dec:     bencode.NewDecoder(conn),
+		pending: make(map[string][]nreplMsg),
+	}
+}
+
+func (c *nreplClient) send(req nreplMsg) {
+	c.t.Helper()
+	if err := c.enc.Encode(req); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// next returns the next response to the request with the given id
+func (c *nreplClient) next(id string) nreplMsg {
+	c.t.Helper()
+	if msgs := c.pending[id]; len(msgs) > 0 {
+		c.pending[id] = msgs[1:]
+		return msgs[0]
+	}
+	for {
+		v, err := c.dec.Decode()
+		if err != nil {
+			c.t.Fatalf("waiting for a response to %s: %v", id, err)
+		}
+		msg, ok := v.(nreplMsg)
+		if !ok {
+			c.t.Fatalf("response isn't a dictionary: %v", v)
+		}
+		if msg["id"] == id {
+			return msg
+		}
+		other, _ := msg["id"].(string)
+		c.pending[other] = append(c.pending[other], msg)
+	}
+}
+
+// responses returns every response to the request with the given id, up
+// to and including the one marked as done
+func (c *nreplClient) responses(id string) []nreplMsg {
+	c.t.Helper()
+	var msgs []nreplMsg
+	for {
+		msg := c.next(id)
+		msgs = append(msgs, msg)
+		for _, s := range statuses(msg) {
+			if s == "done" {
+				return msgs
+			}
+		}
+	}
+}
+
+// request sends a request and waits for all of its responses
+func (c *nreplClient) request(req nreplMsg) []nreplMsg {
+	c.t.Helper()
+	c.send(req)
+	return c.responses(req["id"].(string))
+}
+
+// clone creates a new session, returning its id
+func (c *nreplClient) clone() string {
+	c.t.Helper()
+	msgs := c.request(nreplMsg{"op": "clone", "id": "clone"})
+	session, ok := msgs[0]["new-session"].(string)
+	if !ok {
+		c.t.Fatalf("clone didn't return a session: %v", msgs)
+	}
+	return session
+}
+
+func statuses(msg nreplMsg) []string {
+	vals, _ := msg["status"].([]interface{})
+	status := make([]string, len(vals))
+	for i, v := range vals {
+		status[i], _ = v.(string)
+	}
+	return status
+}
+
+// collect gathers a string field from each of a set of responses
+func collect(msgs []nreplMsg, field string) []string {
+	var vals []string
+	for _, msg := range msgs {
+		if v, ok := msg[field].(string); ok {
+			vals = append(vals, v)
+		}
+	}
+	return vals
+}
+
+// allStatuses joins the statuses across a set of responses
+func allStatuses(msgs []nreplMsg) string {
+	var status []string
+	for _, msg := range msgs {
+		status = append(status, statuses(msg)...)
+	}
+	return strings.Join(status, " ")
+}
+
+func TestNREPLEval(t *testing.T) {
+	c := dialNREPL(t)
+	session := c.clone()
+
+	msgs := c.request(nreplMsg{
+		"op": "eval", "id": "1", "session": session,
+		"code": `(println "hello") (define x 40) (+ x 2)`,
+	})
+	if out := strings.Join(collect(msgs, "out"), ""); out != "hello\n" {
+		t.Errorf("out was %q", out)
+	}
+	if values := collect(msgs, "value"); len(values) != 3 || values[2] != "42" {
+		t.Errorf("values were %q", values)
+	}
+	for _, msg := range msgs {
+		if msg["session"] != session {
+			t.Errorf("response for the wrong session: %v", msg)
+		}
+	}
+
+	// Definitions persist within a session but not across sessions
+	msgs = c.request(nreplMsg{"op": "eval", "id": "2", "session": session, "code": "x"})
+	if values := collect(msgs, "value"); len(values) != 1 || values[0] != "40" {
+		t.Errorf("values were %q", values)
+	}
+	msgs = c.request(nreplMsg{"op": "eval", "id": "3", "session": c.clone(), "code": "x"})
+	if status := allStatuses(msgs); status != "eval-error done" {
+		t.Errorf("status was %q", status)
+	}
+	if errs := collect(msgs, "err"); len(errs) != 1 {
+		t.Errorf("err was %q", errs)
+	}
+}
+
+func TestNREPLInterrupt(t *testing.T) {
+	c := dialNREPL(t)
+	session := c.clone()
+
+	msgs := c.request(nreplMsg{"op": "interrupt", "id": "idle", "session": session})
+	if status := allStatuses(msgs); status != "session-idle done" {
+		t.Errorf("interrupting an idle session: status was %q", status)
+	}
+
+	c.send(nreplMsg{
+		"op": "eval", "id": "spin", "session": session,
+		"code": `(println "started") (while #t nil)`,
+	})
+	// Output may arrive in pieces, but once it's all there the loop is running
+	var out string
+	for out != "started\n" {
+		msg := c.next("spin")
+		text, ok := msg["out"].(string)
+		if !ok {
+			t.Fatalf("expected output before the loop, got %v", msg)
+		}
+		out += text
+	}
+
+	msgs = c.request(nreplMsg{"op": "interrupt", "id": "wrong", "session": session, "interrupt-id": "other"})
+	if status := allStatuses(msgs); status != "error interrupt-id-mismatch done" {
+		t.Errorf("interrupting the wrong eval: status was %q", status)
+	}
+
+	msgs = c.request(nreplMsg{"op": "interrupt", "id": "stop", "session": session, "interrupt-id": "spin"})
+	if status := allStatuses(msgs); status != "done" {
+		t.Errorf("interrupting the eval: status was %q", status)
+	}
+
+	msgs = c.responses("spin")
+	if errs := collect(msgs, "err"); len(errs) != 1 || !strings.Contains(errs[0], "Evaluation interrupted") {
+		t.Errorf("interrupted eval: err was %q", errs)
+	}
+	if status := allStatuses(msgs); status != "interrupted done" {
+		t.Errorf("interrupted eval: status was %q", status)
+	}
+
+	// The session is still usable afterwards
+	msgs = c.request(nreplMsg{"op": "eval", "id": "after", "session": session, "code": "(+ 1 2)"})
+	if values := collect(msgs, "value"); len(values) != 1 || values[0] != "3" {
+		t.Errorf("values were %q", values)
+	}
+}
+
+func TestNREPLClose(t *testing.T) {
+	c := dialNREPL(t)
+	session := c.clone()
+
+	msgs := c.request(nreplMsg{"op": "close", "id": "close", "session": session})
+	if status := allStatuses(msgs); status != "session-closed done" {
+		t.Errorf("status was %q", status)
+	}
+
+	msgs = c.request(nreplMsg{"op": "ls-sessions", "id": "ls"})
+	if sessions, _ := msgs[0]["sessions"].([]interface{}); len(sessions) != 0 {
+		t.Errorf("closed session is still listed: %v", sessions)
+	}
+
+	msgs = c.request(nreplMsg{"op": "eval", "id": "eval", "session": session, "code": "1"})
+	if status := allStatuses(msgs); status != "error unknown-session done" {
+		t.Errorf("eval in a closed session: status was %q", status)
+	}
+}
+
+func TestNREPLCompletions(t *testing.T) {
+	c := dialNREPL(t)
+	session := c.clone()
+	c.request(nreplMsg{
+		"op": "eval", "id": "def", "session": session,
+		"code": `(define re-value 1) (defn re-double (x) (* x 2))`,
+	})
+
+	msgs := c.request(nreplMsg{"op": "completions", "id": "complete", "session": session, "prefix": "re-"})
+	candidates, _ := msgs[0]["completions"].([]interface{})
+	kinds := make(map[string]string)
+	for _, v := range candidates {
+		candidate, _ := v.(nreplMsg)
+		name, _ := candidate["candidate"].(string)
+		if !strings.HasPrefix(name, "re-") {
+			t.Errorf("candidate %q doesn't match the prefix", name)
+		}
+		kinds[name], _ = candidate["type"].(string)
+	}
+	want := map[string]string{
+		"re-seq":    "function",
+		"re-value":  "var",
+		"re-double": "function",
+	}
+	for name, kind := range want {
+		if kinds[name] != kind {
+			t.Errorf("%s: got type %q, want %q", name, kinds[name], kind)
+		}
+	}
+
+	// Names defined in another session aren't offered
+	msgs = c.request(nreplMsg{"op": "completions", "id": "other", "session": c.clone(), "prefix": "re-d"})
+	if candidates, _ := msgs[0]["completions"].([]interface{}); len(candidates) != 0 {
+		t.Errorf("completions from another session: %v", candidates)
+	}
+}
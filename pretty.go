@@ -0,0 +1,352 @@
+package gigl
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+	Pretty printing
+
+	This is a Wadler style pretty printer ("A prettier printer") with the
+	`align` extension from Leijen's wl-pprint. Values are first converted
+	into a document describing the ways that they can be laid out and then
+	the document is rendered, choosing to break groups over multiple lines
+	only when they won't fit in the remaining width.
+
+	Lists that look like code are laid out the way a Lisp programmer would
+	indent them by hand: special forms keep their first few arguments on
+	the same line as the head and indent their body by two spaces, while
+	function calls align their arguments under the first argument.
+
+	Documents are built by the same printer that String uses (see
+	printer.go) so atoms, key order and datum labels for cycles all come
+	out exactly as they do on a single line.
+*/
+
+type docKind int
+
+const (
+	docText     docKind = iota // a literal piece of text
+	docLine                    // a space if flat, otherwise a newline
+	docConcat                  // a sequence of documents
+	docNest                    // increase the indent of a document
+	docAlign                   // set the indent of a document to the current column
+	docGroup                   // try to lay out a document on a single line
+	docHardLine                // always a newline: stops the enclosing groups being flat
+)
+
+type doc struct {
+	kind     docKind
+	text     string
+	indent   int
+	children []*doc
+}
+
+func text(s string) *doc                      { return &doc{kind: docText, text: s} }
+func line() *doc                              { return &doc{kind: docLine} }
+func hardLine() *doc                          { return &doc{kind: docHardLine} }
+func concat(docs ...*doc) *doc                { return &doc{kind: docConcat, children: docs} }
+func nest(i int, d *doc) *doc                 { return &doc{kind: docNest, indent: i, children: []*doc{d}} }
+func align(d *doc) *doc                       { return &doc{kind: docAlign, children: []*doc{d}} }
+func group(d *doc) *doc                       { return &doc{kind: docGroup, children: []*doc{d}} }
+func bracket(open, close string, d *doc) *doc { return concat(text(open), d, text(close)) }
+
+// join documents with a separator document between each pair
+func join(sep *doc, docs []*doc) *doc {
+	joined := make([]*doc, 0, 2*len(docs))
+	for i, d := range docs {
+		if i > 0 {
+			joined = append(joined, sep)
+		}
+		joined = append(joined, d)
+	}
+	return concat(joined...)
+}
+
+// Special forms and the number of arguments that stay on the same line as
+// the head of the form. Everything after that is treated as a body and is
+// indented by two spaces.
+var specialIndent = map[SYMBOL]int{
+	"begin":          0,
+	"cond":           0,
+	"define":         1,
+	"defn":           2,
+	"defmacro":       2,
+	"lambda":         1,
+	"λ":              1,
+	"let":            1,
+	"when":           1,
+	"unless":         1,
+	"while":          1,
+	"case":           1,
+	"do":             2,
+	"with-open-file": 1,
+}
+
+// Reader shorthand for quoting forms
+var quotePrefixes = map[SYMBOL]string{
+	"quote":            "'",
+	"quasiquote":       "`",
+	"unquote":          "~",
+	"unquote-splicing": "~@",
+}
+
+// toDoc converts a value into a document describing its possible layouts,
+// labelling nodes that are part of a cycle as print does
+func (p *printer) toDoc(val lispVal) *doc {
+	if id, ok := nodeID(val); ok {
+		if label, needed := p.labels[id]; needed {
+			if label >= 0 {
+				return text(fmt.Sprintf("#%d#", label))
+			}
+			p.labels[id] = p.nextLabel
+			prefix := fmt.Sprintf("#%d=", p.nextLabel)
+			p.nextLabel++
+			return concat(text(prefix), p.layout(val))
+		}
+	}
+	return p.layout(val)
+}
+
+func (p *printer) layout(val lispVal) *doc {
+	switch v := val.(type) {
+	case *LispList:
+		return p.listDoc(v.toSlice())
+
+	case *LazySeq:
+		vals, err := v.toSlice()
+		if err != nil {
+			return text(p.atom(v))
+		}
+		return p.listDoc(vals)
+
+	case []lispVal:
+		return p.seqDoc("[", "]", v)
+
+	case VECTOR:
+		return p.seqDoc("[", "]", v)
+
+	case SET:
+		return p.seqDoc("#{", "}", p.sortedKeys(children(v)))
+
+	case MAP:
+		keys := make([]lispVal, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		entries := make([]*doc, len(keys))
+		for i, k := range p.sortedKeys(keys) {
+			entries[i] = concat(p.toDoc(k), text(" "), align(p.toDoc(v[k])))
+		}
+		// Keys all line up under the first one
+		return group(bracket("{", "}", align(join(concat(text(","), line()), entries))))
+
+	default:
+		return text(p.atom(val))
+	}
+}
+
+// needsLabel reports whether a value is part of a cycle
+func (p *printer) needsLabel(val lispVal) bool {
+	id, ok := nodeID(val)
+	if !ok {
+		return false
+	}
+	_, needed := p.labels[id]
+	return needed
+}
+
+// atom prints a value that has no layout of its own
+func (p *printer) atom(val lispVal) string {
+	a := &printer{readable: p.readable}
+	a.print(val)
+	return a.String()
+}
+
+// a collection of data with every element aligned under the first
+func (p *printer) seqDoc(open, close string, vals []lispVal) *doc {
+	docs := make([]*doc, len(vals))
+	for i, v := range vals {
+		docs[i] = p.toDoc(v)
+	}
+	return group(bracket(open, close, align(join(line(), docs))))
+}
+
+func (p *printer) listDoc(vals []lispVal) *doc {
+	if len(vals) == 0 {
+		return text("()")
+	}
+	head, ok := vals[0].(SYMBOL)
+	if !ok {
+		// Not code so treat it as data
+		return p.seqDoc("(", ")", vals)
+	}
+
+	if prefix, ok := quotePrefixes[head]; ok && len(vals) == 2 {
+		return concat(text(prefix), p.toDoc(vals[1]))
+	}
+
+	args := make([]*doc, len(vals)-1)
+	for i, v := range vals[1:] {
+		if bindings, ok := v.(*LispList); ok && i == 0 && head == "let" && !p.needsLabel(v) {
+			// Line the bindings up with each other
+			args[i] = p.seqDoc("(", ")", bindings.toSlice())
+			continue
+		}
+		args[i] = p.toDoc(v)
+	}
+	if len(args) == 0 {
+		return text("(" + string(head) + ")")
+	}
+
+	n, special := specialIndent[head]
+	if !special {
+		// (f a
+		//    b)
+		return group(bracket("("+string(head)+" ", ")", align(join(line(), args))))
+	}
+
+	// (defn name (args)
+	//   body)
+	if n > len(args) {
+		n = len(args)
+	}
+	first := []*doc{text("(" + string(head))}
+	for _, a := range args[:n] {
+		first = append(first, text(" "), a)
+	}
+	body := make([]*doc, 0, 2*(len(args)-n))
+	for _, a := range args[n:] {
+		body = append(body, line(), a)
+	}
+	// Align so that the body is indented relative to the start of the form
+	return group(align(concat(concat(first...), nest(2, concat(body...)), text(")"))))
+}
+
+// An item on the render stack
+type renderItem struct {
+	indent int
+	flat   bool
+	d      *doc
+}
+
+// fits reports whether everything up to the next line break in the
+// remaining items fits within width columns.
+func fits(width int, items []renderItem) bool {
+	for width >= 0 && len(items) > 0 {
+		item := items[len(items)-1]
+		items = items[:len(items)-1]
+
+		switch item.d.kind {
+		case docText:
+			width -= utf8.RuneCountInString(item.d.text)
+		case docLine:
+			if !item.flat {
+				return true
+			}
+			width--
+		case docHardLine:
+			// A flat group can't contain a forced line break
+			return !item.flat
+		case docConcat:
+			for i := len(item.d.children) - 1; i >= 0; i-- {
+				items = append(items, renderItem{item.indent, item.flat, item.d.children[i]})
+			}
+		case docNest:
+			items = append(items, renderItem{item.indent + item.d.indent, item.flat, item.d.children[0]})
+		case docAlign, docGroup:
+			items = append(items, renderItem{item.indent, item.flat, item.d.children[0]})
+		}
+	}
+	return width >= 0
+}
+
+// render lays out a document within the given width
+func render(w io.Writer, d *doc, width int) error {
+	var b strings.Builder
+	col := 0
+	stack := []renderItem{{0, false, d}}
+
+	for len(stack) > 0 {
+		item := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		switch item.d.kind {
+		case docText:
+			b.WriteString(item.d.text)
+			col += utf8.RuneCountInString(item.d.text)
+
+		case docLine, docHardLine:
+			if item.flat && item.d.kind == docLine {
+				b.WriteByte(' ')
+				col++
+			} else {
+				b.WriteString("\n" + strings.Repeat(" ", item.indent))
+				col = item.indent
+			}
+
+		case docConcat:
+			for i := len(item.d.children) - 1; i >= 0; i-- {
+				stack = append(stack, renderItem{item.indent, item.flat, item.d.children[i]})
+			}
+
+		case docNest:
+			stack = append(stack, renderItem{item.indent + item.d.indent, item.flat, item.d.children[0]})
+
+		case docAlign:
+			stack = append(stack, renderItem{col, item.flat, item.d.children[0]})
+
+		case docGroup:
+			flat := item.flat
+			if !flat {
+				// Check the group on its own line along with whatever
+				// follows it up to the next line break.
+				trial := append(append([]renderItem{}, stack...), renderItem{item.indent, true, item.d.children[0]})
+				flat = fits(width-col, trial)
+			}
+			stack = append(stack, renderItem{item.indent, flat, item.d.children[0]})
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// PrettyPrint writes a readable representation of v to w, breaking it
+// over multiple lines and indenting it so that it fits within width
+// columns wherever possible.
+func PrettyPrint(w io.Writer, v lispVal, width int) error {
+	p := &printer{readable: true, labels: make(map[interface{}]int)}
+	p.findCycles(v, make(map[interface{}]bool), make(map[interface{}]bool))
+	return render(w, p.toDoc(v), width)
+}
+
+// prettyString is PrettyPrint to a string
+func prettyString(val lispVal, width int) string {
+	var b strings.Builder
+	PrettyPrint(&b, val, width)
+	return b.String()
+}
+
+// pretty print a value to the current output port: (pp x [width])
+func (e *Evaluator) pp(lst ...lispVal) (lispVal, error) {
+	width := 80
+	switch len(lst) {
+	case 1:
+	case 2:
+		w, err := getIndex(lst[1])
+		if err != nil {
+			return nil, err
+		}
+		width = w
+	default:
+		return nil, fmt.Errorf("pp takes a value and an optional width")
+	}
+	if err := PrettyPrint(e.output.writer, lst[0], width); err != nil {
+		return nil, err
+	}
+	return e.newline()
+}
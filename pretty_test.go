@@ -0,0 +1,43 @@
+package gigl
+
+import "testing"
+
+func TestPrettyPrint(t *testing.T) {
+	cyclic := []lispVal{1.0, 2.0, nil}
+	cyclic[2] = cyclic
+
+	tests := []struct {
+		val   lispVal
+		width int
+		want  string
+	}{
+		{List(1.0, 2.0, 3.0), 80, "(1 2 3)"},
+		{List(1.0, 2.0, 3.0), 4, "(1\n 2\n 3)"},
+		{
+			List(SYMBOL("let"),
+				List(List(SYMBOL("x"), 1.0), List(SYMBOL("yyyyyyyyyyy"), 2.0)),
+				List(SYMBOL("+"), SYMBOL("x"), SYMBOL("yyyyyyyyyyyyyy"))),
+			20,
+			"(let ((x 1)\n      (yyyyyyyyyyy 2))\n  (+ x\n     yyyyyyyyyyyyyy))",
+		},
+		{
+			List(SYMBOL("defn"), SYMBOL("f"), List(SYMBOL("x")), List(SYMBOL("*"), SYMBOL("x"), 2.0)),
+			15,
+			"(defn f (x)\n  (* x 2))",
+		},
+		{
+			MAP{KEYWORD("a"): 1.0, KEYWORD("bbbbbbb"): []lispVal{"x", "yyyyyyyyyyy"}},
+			20,
+			"{:a 1,\n :bbbbbbb [\"x\"\n           \"yyyyyyyyyyy\"]}",
+		},
+		{List(SYMBOL("quote"), List(1.0, 2.0)), 80, "'(1 2)"},
+		{cyclic, 80, "#0=[1 2 #0#]"},
+		{cyclic, 5, "#0=[1\n    2\n    #0#]"},
+	}
+
+	for _, tt := range tests {
+		if got := prettyString(tt.val, tt.width); got != tt.want {
+			t.Errorf("%s at width %d:\ngot\n%s\nwant\n%s", String(tt.val), tt.width, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package gigl
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	The printer
+
+	There are two ways of printing a value:
+	  - write: the output can be read back in by the reader to give an
+	    equal value (for everything that has a readable syntax).
+	  - display: the output is meant for humans so strings and characters
+	    are printed without any quoting.
+
+	Values that contain themselves are printed using datum labels so
+	that printing always terminates: the first time we hit a node that is
+	part of a cycle it is labelled with #n= and later references to it are
+	printed as #n#. The reader has no way to build a cyclic value so it
+	rejects datum labels: cyclic values are the one case where the output
+	of write can't be read back in.
+
+	Pretty printing, which wraps collections that don't fit on one line,
+	is layered on top of this printer in pretty.go.
+*/
+
+type printer struct {
+	readable  bool
+	labels    map[interface{}]int // nodes that need a label: -1 until one is assigned
+	nextLabel int
+	b         strings.Builder
+}
+
+// Convert a lispVal to a string that can be read back in
+func String(val lispVal) string {
+	return newPrinter(val, true).String()
+}
+
+// Display converts a lispVal to a string for humans
+func Display(val lispVal) string {
+	return newPrinter(val, false).String()
+}
+
+func newPrinter(val lispVal, readable bool) *printer {
+	p := &printer{readable: readable, labels: make(map[interface{}]int)}
+	p.findCycles(val, make(map[interface{}]bool), make(map[interface{}]bool))
+	p.print(val)
+	return p
+}
+
+func (p *printer) String() string {
+	return p.b.String()
+}
+
+// mapID identifies maps and sets, which don't have a usable pointer type
+type mapID struct {
+	kind string
+	ptr  uintptr
+}
+
+// nodeID identifies collections so that we can spot them when they recur
+func nodeID(val lispVal) (interface{}, bool) {
+	switch v := val.(type) {
+	case *LispList:
+		if v.root != nil {
+			return v.root, true
+		}
+	case []lispVal:
+		if len(v) > 0 {
+			return &v[0], true
+		}
+	case VECTOR:
+		if len(v) > 0 {
+			return &v[0], true
+		}
+	case MAP:
+		if len(v) > 0 {
+			return mapID{"map", reflect.ValueOf(v).Pointer()}, true
+		}
+	case SET:
+		if len(v) > 0 {
+			return mapID{"set", reflect.ValueOf(v).Pointer()}, true
+		}
+	}
+	return nil, false
+}
+
+// children returns the values directly contained in a collection
+func children(val lispVal) []lispVal {
+	switch v := val.(type) {
+	case *LispList:
+		return v.toSlice()
+	case []lispVal:
+		return v
+	case VECTOR:
+		return v
+	case MAP:
+		vals := make([]lispVal, 0, 2*len(v))
+		for k, x := range v {
+			vals = append(vals, k, x)
+		}
+		return vals
+	case SET:
+		vals := make([]lispVal, 0, len(v))
+		for k := range v {
+			vals = append(vals, k)
+		}
+		return vals
+	}
+	return nil
+}
+
+// findCycles marks every node that is reachable from itself
+func (p *printer) findCycles(val lispVal, onStack, visited map[interface{}]bool) {
+	id, ok := nodeID(val)
+	if !ok {
+		return
+	}
+	if onStack[id] {
+		p.labels[id] = -1
+		return
+	}
+	if visited[id] {
+		return
+	}
+	visited[id] = true
+	onStack[id] = true
+	for _, child := range children(val) {
+		p.findCycles(child, onStack, visited)
+	}
+	onStack[id] = false
+}
+
+// print writes val to the buffer
+func (p *printer) print(val lispVal) {
+	if id, ok := nodeID(val); ok {
+		if label, needed := p.labels[id]; needed {
+			if label >= 0 {
+				fmt.Fprintf(&p.b, "#%d#", label)
+				return
+			}
+			p.labels[id] = p.nextLabel
+			fmt.Fprintf(&p.b, "#%d=", p.nextLabel)
+			p.nextLabel++
+		}
+	}
+
+	switch val := val.(type) {
+	case nil:
+		p.b.WriteString("nil")
+
+	case bool:
+		if val {
+			p.b.WriteString("#t")
+		} else {
+			p.b.WriteString("#f")
+		}
+
+	case float64:
+		p.b.WriteString(formatNumber(val))
+
+	case string:
+		if p.readable {
+			p.b.WriteString(quoteString(val))
+		} else {
+			p.b.WriteString(val)
+		}
+
+	case CHAR:
+		if p.readable {
+			p.b.WriteString(charLiteral(val))
+		} else {
+			p.b.WriteRune(rune(val))
+		}
+
+	case SYMBOL:
+		p.b.WriteString(string(val))
+
+	case KEYWORD:
+		p.b.WriteString(":" + string(val))
+
+	case REGEX:
+		p.b.WriteString(regexLiteral(val))
+
+	case *LispList:
+		p.printSeq("(", ")", val.toSlice())
+
+	case LispList:
+		p.printSeq("(", ")", val.toSlice())
+
+	case *LazySeq:
+		vals, err := val.toSlice()
+		if err != nil {
+			fmt.Fprintf(&p.b, "#<lazy-seq error: %v>", err)
+			return
+		}
+		p.printSeq("(", ")", vals)
+
+	case []lispVal:
+		p.printSeq("[", "]", val)
+
+	case VECTOR:
+		p.printSeq("[", "]", val)
+
+	case MAP:
+		keys := make([]lispVal, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		keys = p.sortedKeys(keys)
+		entries := make([]lispVal, 0, 2*len(keys))
+		for _, k := range keys {
+			entries = append(entries, k, val[k])
+		}
+		p.printMap(entries)
+
+	case SET:
+		p.printSeq("#{", "}", p.sortedKeys(children(val)))
+
+	case func(...lispVal) (lispVal, error), *Procedure:
+		p.b.WriteString("#<procedure>")
+
+	case error:
+		fmt.Fprintf(&p.b, "#<error: %v>", val)
+
+	default:
+		if s, ok := val.(fmt.Stringer); ok {
+			p.b.WriteString(s.String())
+			return
+		}
+		fmt.Fprintf(&p.b, "#<%v>", val)
+	}
+}
+
+// printSeq prints a sequence of values between brackets
+func (p *printer) printSeq(open, close string, vals []lispVal) {
+	p.b.WriteString(open)
+	for i, v := range vals {
+		if i > 0 {
+			p.b.WriteByte(' ')
+		}
+		p.print(v)
+	}
+	p.b.WriteString(close)
+}
+
+// printMap prints alternating keys and values
+func (p *printer) printMap(entries []lispVal) {
+	p.b.WriteString("{")
+	for i := 0; i < len(entries); i += 2 {
+		if i > 0 {
+			p.b.WriteString(", ")
+		}
+		p.print(entries[i])
+		p.b.WriteByte(' ')
+		p.print(entries[i+1])
+	}
+	p.b.WriteString("}")
+}
+
+// sortedKeys orders map and set keys by their printed form so that output
+// is deterministic.
+func (p *printer) sortedKeys(keys []lispVal) []lispVal {
+	printed := make(map[int]string, len(keys))
+	for i, k := range keys {
+		printed[i] = String(k)
+	}
+	ix := make([]int, len(keys))
+	for i := range ix {
+		ix[i] = i
+	}
+	sort.Slice(ix, func(a, b int) bool { return printed[ix[a]] < printed[ix[b]] })
+
+	sorted := make([]lispVal, len(keys))
+	for i, j := range ix {
+		sorted[i] = keys[j]
+	}
+	return sorted
+}
+
+// formatNumber prints numbers so that they read back in as the same value:
+// integral values are printed without a decimal point.
+func formatNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "##NaN"
+	case math.IsInf(f, 1):
+		return "##Inf"
+	case math.IsInf(f, -1):
+		return "##-Inf"
+	case f == math.Trunc(f) && math.Abs(f) < 1e15:
+		return strconv.FormatInt(int64(f), 10)
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,116 @@
+package gigl
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestWriteReadsBack checks that the output of write reads back in as an
+// equal value for every type with a readable syntax
+func TestWriteReadsBack(t *testing.T) {
+	tests := []string{
+		"nil", "#t", "#f",
+		"0", "42", "-7", "1.5", "-0.25", "1e21", "1.5e-7", "123456789012",
+		"##Inf", "##-Inf",
+		`""`, `"plain"`, `"a\nb\tc\r"`, `"say \"hi\" \\ back"`, `"λ→😀"`, `"\u{7}\u{0}"`,
+		`#\a`, `#\space`, `#\newline`, `#\λ`, `#\x0`, `#\(`,
+		":k", ":with-dash", "'sym", "'λ", "'+",
+		"'()", "'(1 2 3)", `'(1 (2 "three" (:four)) [5])`,
+		"'(quote x)", "'(quasiquote (a (unquote b) (unquote-splicing c)))",
+		"[]", `[1 "two" :three [4]]`,
+		"{}", `{:a 1 "b" [2 3] 4 {:nested #{:x}}}`,
+		"#{}", `#{1 "two" :three}`,
+	}
+
+	e := newTestEvaluator(t)
+	for _, src := range tests {
+		val, err := evalSource(e, src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", src, err)
+			continue
+		}
+		written := String(val)
+		back, err := NewTokeniser().read(written)
+		if err != nil {
+			t.Errorf("%s: %s doesn't read back in: %v", src, written, err)
+			continue
+		}
+		if !reflect.DeepEqual(val, back) {
+			t.Errorf("%s: %s read back as %#v, want %#v", src, written, back, val)
+		}
+
+		// The same holds from inside gigl
+		same, err := evalSource(e, "(eq? "+src+" (read-string (str "+src+")))")
+		if err != nil || same != true {
+			t.Errorf("%s: (read-string (str x)) gave %v, %v", src, same, err)
+		}
+	}
+
+	// NaN isn't equal to itself
+	if back, err := NewTokeniser().read(String(math.NaN())); err != nil || !math.IsNaN(back.(float64)) {
+		t.Errorf("NaN read back as %v, %v", back, err)
+	}
+
+	// Regexes can't be compared so check they are written the same way
+	for _, pattern := range []string{`\d+`, `"quoted"`, `a\"b`, `a\\"b`, `\\\\"`} {
+		re, err := compileRegex(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		written := String(re)
+		back, err := NewTokeniser().read(written)
+		if err != nil {
+			t.Errorf("%s: %s doesn't read back in: %v", pattern, written, err)
+			continue
+		}
+		if String(back) != written {
+			t.Errorf("%s: %s read back as %s", pattern, written, String(back))
+		}
+	}
+}
+
+func TestDisplay(t *testing.T) {
+	tests := []struct {
+		val  lispVal
+		want string
+	}{
+		{"a\nb", "a\nb"},
+		{CHAR('λ'), "λ"},
+		{List("a", CHAR('b'), KEYWORD("c")), "(a b :c)"},
+		{nil, "nil"},
+		{2.0, "2"},
+	}
+
+	for _, tt := range tests {
+		if got := Display(tt.val); got != tt.want {
+			t.Errorf("Display(%s) = %q, want %q", String(tt.val), got, tt.want)
+		}
+	}
+}
+
+// Cyclic values are printed with datum labels, which the reader rejects
+// rather than misreading as symbols
+func TestCyclicValuesAreUnreadable(t *testing.T) {
+	cyclic := []lispVal{1.0, nil}
+	cyclic[1] = cyclic
+	written := String(cyclic)
+	if written != "#0=[1 #0#]" {
+		t.Fatalf("cyclic vector written as %s", written)
+	}
+
+	for _, src := range []string{written, "#0=(1 #0#)", "#12#"} {
+		_, err := NewTokeniser().read(src)
+		if err == nil || !strings.Contains(err.Error(), "datum label") {
+			t.Errorf("%s: expected a datum label error, got %v", src, err)
+		}
+	}
+
+	// Symbols that only look a little like labels are fine
+	for _, src := range []string{"#=", "a#0=", "#0x="} {
+		if val, err := NewTokeniser().read(src); err != nil || val != SYMBOL(src) {
+			t.Errorf("%s: read as %v, %v", src, val, err)
+		}
+	}
+}
@@ -2,107 +2,74 @@ package gigl
 
 import (
 	"fmt"
-	"regexp"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
 )
 
-// Regex objects for constructing atoms
-// (Ensure that keywords and symbols contain valid characters)
+// Reader macros for quoting and unquoting forms
 var quotes = map[string]SYMBOL{
 	"'": SYMBOL("quote"), "`": SYMBOL("quasiquote"),
 	"~": SYMBOL("unquote"), "~@": SYMBOL("unquote-splicing"),
 }
 
-type tag struct {
-	name  string
-	regex *regexp.Regexp
-}
-
 type token struct {
 	Tag  string
 	Text string
+	Span Span
 }
 
 // Tokeniser turns a string into a slice of tokens for parsing
 type Tokeniser struct {
-	tags   []tag
-	input  string
-	ix     int
-	tokens []token
+	input     string
+	ix        int
+	tokens    []token
+	exhausted bool
+
+	// keepTrivia makes the tokeniser emit WHITESPACE, NEWLINE, COMMA,
+	// COMMENT and BLOCK_COMMENT tokens for building a concrete syntax tree
+	keepTrivia bool
+
+	// features that reader conditionals can test for
+	features map[string]bool
+
+	// parser state: the collections that are currently open and the
+	// syntax errors found so far
+	open   []token
+	errors SyntaxErrors
 }
 
 // NewTokeniser constructs a new Tokeniser...!
 func NewTokeniser() *Tokeniser {
-	return &Tokeniser{
-		tags: []tag{
-			tag{"LINECOMMENT", regexp.MustCompile("^;;")},
-			tag{"LIST_START", regexp.MustCompile(`^\(`)},
-			tag{"LIST_END", regexp.MustCompile(`^\)`)},
-			tag{"VEC_START", regexp.MustCompile(`^\[`)},
-			tag{"VEC_END", regexp.MustCompile(`^\]`)},
-			tag{"MAP_START", regexp.MustCompile("^{")},
-			tag{"SET_START", regexp.MustCompile("^#{")},
-			tag{"MAP_OR_SET_END", regexp.MustCompile("^}")},
-			tag{"COMPLEX", regexp.MustCompile(`^-?\d+\.?\d*[+-]\d+\.?\d*j`)},
-			tag{"COMPLEX_PURE", regexp.MustCompile(`^-?\d+\.?\d*j`)},
-			tag{"FLOAT", regexp.MustCompile(`^-?\d+\.\d+`)},
-			tag{"INT", regexp.MustCompile(`^-?\d+`)},
-			tag{"BOOL", regexp.MustCompile(`^#[tf]`)},
-			tag{"SPLICE", regexp.MustCompile("^~@")},
-			tag{"QUOTE", regexp.MustCompile("^['`~]")},
-			tag{"NEWLINE", regexp.MustCompile(`^\n`)},
-			tag{"WHITESPACE", regexp.MustCompile(`^\s+`)},
-			tag{"COMMA", regexp.MustCompile(`^,`)},
-			tag{"STRING", regexp.MustCompile(`^"([^"]*)"`)},
-			tag{"KEYWORD", regexp.MustCompile("^:[^(){}\\[\\],'`@:; \t\n]*")},
-			tag{"SYMBOL", regexp.MustCompile("^[^(){}\\[\\],'`@:; \t\n]*")},
-			tag{"ERROR", regexp.MustCompile(".*")},
-		},
-	}
-}
-
-// Tokenise splits an input string into tokens for parsing
-func (t *Tokeniser) Tokenise(s string) {
-	t.tokens = make([]token, 0)
-	t.ix = 0
-	t.input = s
-	inComment := false
-
-	for len(s) > 0 {
-		for _, tag := range t.tags {
-			if loc := tag.regex.FindStringIndex(s); loc != nil {
-				switch tag.name {
-				case "LINECOMMENT":
-					inComment = true
-
-				case "WHITESPACE", "COMMA":
-					// A la Clojure/edn, commas are also whitespace
-
-				case "NEWLINE":
-					// Terminate a line comment if we are in one
-					inComment = false
-
-				default:
-					if !inComment {
-						t.tokens = append(t.tokens, token{tag.name, s[loc[0]:loc[1]]})
-					}
-				}
+	return &Tokeniser{features: defaultFeatures()}
+}
 
-				s = s[loc[1]:]
-				break
-			}
-		}
+// isTrivia reports whether tokens with the given tag have no meaning to the
+// parser. A la Clojure/edn, commas are whitespace.
+func isTrivia(tag string) bool {
+	switch tag {
+	case "WHITESPACE", "NEWLINE", "COMMA", "COMMENT", "BLOCK_COMMENT":
+		return true
 	}
+	return false
 }
 
-// NextToken return the next token in the stream
-func (t *Tokeniser) NextToken() (token, error) {
-	if t.ix >= len(t.tokens) {
-		return token{}, fmt.Errorf("Ran out of tokens")
+// isComment reports whether a trivia tag is a comment
+func isComment(tag string) bool {
+	return tag == "COMMENT" || tag == "BLOCK_COMMENT"
+}
+
+// isClosing reports whether a tag closes a collection
+func isClosing(tag string) bool {
+	switch tag {
+	case "LIST_END", "VEC_END", "MAP_OR_SET_END":
+		return true
 	}
-	tok := t.tokens[t.ix]
-	t.ix++
-	return tok, nil
+	return false
 }
 
 // Tokenise an input string and then parse the result
@@ -111,71 +78,468 @@ func (t *Tokeniser) read(s string) (lispVal, error) {
 	return t.parseTokens()
 }
 
-// Convert tokens into internal data structures
-func (t *Tokeniser) parseTokens() (lispVal, error) {
-	// Pull off the first token
-	token, err := t.NextToken()
+// readFirst parses the first form in s and returns the text of the tokens
+// that follow it. If s ends before the form is complete then complete is
+// false so that the caller can try again with more input, and err says
+// what was left open.
+func (t *Tokeniser) readFirst(s string) (val lispVal, remaining string, complete bool, err error) {
+	t.Tokenise(s)
+	if len(t.tokens) == 0 {
+		return nil, "", false, nil
+	}
+
+	val, err = t.parseTokens()
 	if err != nil {
-		return nil, fmt.Errorf("Syntax error: %v", t.input)
+		return nil, "", !t.exhausted, err
 	}
 
-	switch token.Tag {
-	case "LIST_START":
-		// Start of a list so recuse and build it up
-		lst := make([]lispVal, 0)
-		parsedToken, err := t.parseTokens()
-		for {
-			if parsedToken == "LIST_END" {
-				if err != nil {
-					return nil, fmt.Errorf("Syntax error")
+	if t.ix < len(t.tokens) {
+		remaining = t.input[t.tokens[t.ix].Span.Start.Offset:]
+	}
+	return val, remaining, true, nil
+}
+
+/*
+	Syntax errors
+
+	The parser doesn't stop at the first problem that it finds. Errors are
+	recorded along with where they happened and parsing carries on from
+	the next token so that ReadAll can report every problem in a file at
+	once. Brackets that don't match are resolved by looking at the
+	collections that are currently open: if the bracket closes one of them
+	then the inner collections are treated as unclosed, otherwise it is
+	taken to close the current collection.
+*/
+
+// SyntaxError is a problem with the source text at a particular place
+type SyntaxError struct {
+	Span    Span
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at %v", e.Message, e.Span.Start)
+}
+
+// SyntaxErrors is every problem found while reading some source text
+type SyntaxErrors []*SyntaxError
+
+func (e SyntaxErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Brackets that close each kind of collection
+var closingBrackets = map[string]struct{ tag, text string }{
+	"LIST_START": {"LIST_END", ")"},
+	"VEC_START":  {"VEC_END", "]"},
+	"MAP_START":  {"MAP_OR_SET_END", "}"},
+	"SET_START":  {"MAP_OR_SET_END", "}"},
+}
+
+func (t *Tokeniser) syntaxError(span Span, format string, args ...interface{}) {
+	t.errors = append(t.errors, &SyntaxError{Span: span, Message: fmt.Sprintf(format, args...)})
+}
+
+// parseTokens parses the next form, returning the first syntax error in it
+func (t *Tokeniser) parseTokens() (lispVal, error) {
+	t.errors = nil
+	val, ok := t.parseForm()
+	if !ok {
+		if t.ix < len(t.tokens) {
+			// A closing bracket with nothing to close
+			tok := t.tokens[t.ix]
+			t.ix++
+			t.syntaxError(tok.Span, "Unexpected `%s`", tok.Text)
+		} else {
+			t.syntaxError(t.endSpan(), "Unexpected end of input")
+		}
+	}
+	if len(t.errors) > 0 {
+		return nil, t.errors[0]
+	}
+	return val, nil
+}
+
+// ReadAll parses every form in s. If there are syntax errors then the
+// forms that could be read are returned along with SyntaxErrors describing
+// all of the problems.
+func (t *Tokeniser) ReadAll(s string) ([]lispVal, error) {
+	vals, _, err := t.readAllPositions(s)
+	return vals, err
+}
+
+// readAllPositions is ReadAll that also returns where each form started
+func (t *Tokeniser) readAllPositions(s string) ([]lispVal, []Pos, error) {
+	t.Tokenise(s)
+	t.errors = nil
+	vals := make([]lispVal, 0)
+	positions := make([]Pos, 0)
+	for t.ix < len(t.tokens) {
+		start := t.tokens[t.ix].Span.Start
+		if val, ok := t.parseForm(); ok {
+			vals = append(vals, val)
+			positions = append(positions, start)
+		} else if t.ix < len(t.tokens) {
+			tok := t.tokens[t.ix]
+			t.ix++
+			t.syntaxError(tok.Span, "Unexpected `%s`", tok.Text)
+		}
+	}
+	if len(t.errors) > 0 {
+		return vals, positions, t.errors
+	}
+	return vals, positions, nil
+}
+
+// endSpan is the empty span at the end of the input
+func (t *Tokeniser) endSpan() Span {
+	end := Pos{Offset: 0, Line: 1, Col: 1}
+	if len(t.tokens) > 0 {
+		last := t.tokens[len(t.tokens)-1].Span.End
+		end = last.advance(t.input[last.Offset:])
+	}
+	return Span{end, end}
+}
+
+// parseForm parses the next form, recording any syntax errors. If there is
+// no form before the next closing bracket or the end of the input then ok
+// is false and the closing bracket is left for the caller.
+func (t *Tokeniser) parseForm() (val lispVal, ok bool) {
+	for {
+		if t.ix >= len(t.tokens) {
+			t.exhausted = true
+			return nil, false
+		}
+		tok := t.tokens[t.ix]
+		if isClosing(tok.Tag) {
+			return nil, false
+		}
+		t.ix++
+
+		switch tok.Tag {
+		case "LIST_START", "VEC_START", "MAP_START", "SET_START":
+			return t.parseCollection(tok), true
+
+		case "QUOTE", "SPLICE":
+			// Something is being quoted or unquoted
+			quoted, ok := t.parseForm()
+			if !ok {
+				t.syntaxError(tok.Span, "Missing form after `%s`", tok.Text)
+			}
+			return List(quotes[tok.Text], quoted), true
+
+		case "DATUM_COMMENT":
+			// Skip the next form entirely
+			t.skipForm(tok)
+
+		case "FEATURE_COND":
+			nerrs := len(t.errors)
+			expr, ok := t.parseForm()
+			if !ok {
+				t.syntaxError(tok.Span, "Missing feature after `%s`", tok.Text)
+				return nil, true
+			}
+			// A feature that couldn't be read has already been reported
+			found, err := t.hasFeature(expr)
+			if err != nil && len(t.errors) == nerrs {
+				t.syntaxError(tok.Span, "%v", err)
+			}
+			if found != (tok.Text == "#+") {
+				t.skipForm(tok)
+				continue
+			}
+			val, ok := t.parseForm()
+			if !ok {
+				t.syntaxError(tok.Span, "Missing form after `%s`", tok.Text)
+			}
+			return val, true
+
+		case "READER_COND":
+			if t.ix >= len(t.tokens) || t.tokens[t.ix].Tag != "LIST_START" {
+				t.syntaxError(tok.Span, "`#?` must be followed by a list")
+				return nil, true
+			}
+			t.ix++
+			nerrs := len(t.errors)
+			branches, _ := t.parseCollection(t.tokens[t.ix-1]).(*LispList)
+			if branches == nil {
+				return nil, true
+			}
+			vals := branches.toSlice()
+			i, err := t.selectBranch(vals)
+			if err != nil {
+				if len(t.errors) == nerrs {
+					t.syntaxError(tok.Span, "%v", err)
 				}
-				return List(lst...), nil
+				return nil, true
 			}
-			lst = append(lst, parsedToken)
-			parsedToken, err = t.parseTokens()
+			if i >= 0 {
+				return vals[i], true
+			}
+			// No branch applies so there is nothing to read here
+
+		case "ERROR":
+			t.syntaxError(errorTokenSpan(tok), "%s", errorTokenMessage(tok))
+			return nil, true
+
+		default:
+			// if it"s not a list then it"s an atom
+			val, err := makeAtom(tok)
 			if err != nil {
-				return nil, err
+				t.syntaxError(tok.Span, "%v", err)
+				return nil, true
 			}
+			return val, true
 		}
+	}
+}
 
-	case "LIST_END":
-		return "LIST_END", nil
+// parseCollection parses the contents of a collection after its opening
+// bracket
+func (t *Tokeniser) parseCollection(open token) lispVal {
+	end := closingBrackets[open.Tag]
+	t.open = append(t.open, open)
+	defer func() { t.open = t.open[:len(t.open)-1] }()
 
-	case "VEC_START":
-		lst := make([]lispVal, 0)
-		parsedToken, err := t.parseTokens()
-		for {
-			if parsedToken == "VEC_END" {
-				if err != nil {
-					return nil, fmt.Errorf("Syntax error")
-				}
-				return lst, nil
-			}
-			lst = append(lst, parsedToken)
-			parsedToken, err = t.parseTokens()
+	vals := make([]lispVal, 0)
+	for {
+		val, ok := t.parseForm()
+		if ok {
+			vals = append(vals, val)
+			continue
+		}
+
+		if t.ix >= len(t.tokens) {
+			t.syntaxError(open.Span, "Unclosed `%s` opened", open.Text)
+			return nil
+		}
+		closer := t.tokens[t.ix]
+		if closer.Tag == end.tag {
+			t.ix++
+			break
+		}
+		t.syntaxError(closer.Span, "Unexpected `%s`, expected `%s`", closer.Text, end.text)
+		if t.closesOuter(closer) {
+			// Leave it for the collection that it belongs to
+			return nil
+		}
+		// Most likely a typo for the right bracket
+		t.ix++
+		break
+	}
+
+	var (
+		coll lispVal = vals
+		err  error
+	)
+	switch open.Tag {
+	case "LIST_START":
+		coll = List(vals...)
+	case "MAP_START":
+		coll, err = makeMap(vals)
+	case "SET_START":
+		coll, err = makeSet(vals)
+	}
+	if err != nil {
+		t.syntaxError(open.Span, "%v", err)
+	}
+	return coll
+}
+
+// closesOuter reports whether a closing bracket matches one of the
+// collections enclosing the current one
+func (t *Tokeniser) closesOuter(closer token) bool {
+	for _, open := range t.open[:len(t.open)-1] {
+		if closingBrackets[open.Tag].tag == closer.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+// skipForm reads the next form and throws it away
+func (t *Tokeniser) skipForm(tok token) {
+	if _, ok := t.parseForm(); !ok {
+		t.syntaxError(tok.Span, "Missing form after `%s`", tok.Text)
+	}
+}
+
+// errorTokenMessage describes the problem with an ERROR token from the lexer
+func errorTokenMessage(tok token) string {
+	switch {
+	case strings.HasPrefix(tok.Text, `"`):
+		return "Unterminated string"
+	case strings.HasPrefix(tok.Text, `#"`):
+		return "Unterminated regex"
+	case strings.HasPrefix(tok.Text, "#|"):
+		return "Unterminated block comment"
+	case tok.Text == `#\`:
+		return "Missing character after `#\\`"
+	case strings.HasPrefix(tok.Text, "##") || isDigit(tok.Text[0]) || tok.Text[0] == '-':
+		return fmt.Sprintf("Invalid number `%s`", tok.Text)
+	default:
+		return fmt.Sprintf("Invalid token `%s`", tok.Text)
+	}
+}
+
+// errorTokenSpan is where to report an ERROR token. An unterminated block
+// comment swallows the rest of the input so only its opening is marked.
+func errorTokenSpan(tok token) Span {
+	if strings.HasPrefix(tok.Text, "#|") {
+		return Span{tok.Span.Start, tok.Span.Start.advance("#|")}
+	}
+	return tok.Span
+}
+
+/*
+	Reader conditionals
+
+	Code can be shared between gigl versions and embedding hosts by testing
+	for features when it is read:
+	  #+feature form            read form only if feature is present
+	  #-feature form            read form only if feature is absent
+	  #?(:gigl a :other b)      read the first form whose feature is present,
+	                            falling back to :default
+
+	Features are symbols or keywords and can be combined with (and ...),
+	(or ...) and (not ...) after #+ and #-. If nothing is selected then the
+	reader carries on as if the conditional wasn't there.
+*/
+
+// defaultFeatures are present in every tokeniser
+func defaultFeatures() map[string]bool {
+	return map[string]bool{"gigl": true, runtime.GOOS: true}
+}
+
+// featureName is the name of a feature given as a symbol or keyword
+func featureName(v lispVal) (string, bool) {
+	switch f := v.(type) {
+	case SYMBOL:
+		return string(f), true
+	case KEYWORD:
+		return string(f), true
+	}
+	return "", false
+}
+
+// hasFeature evaluates a feature expression
+func (t *Tokeniser) hasFeature(expr lispVal) (bool, error) {
+	if name, ok := featureName(expr); ok {
+		return t.features[name], nil
+	}
+
+	lst, ok := expr.(*LispList)
+	if !ok || lst.Len() == 0 {
+		return false, fmt.Errorf("Invalid feature expression: %v", String(expr))
+	}
+	vals := lst.toSlice()
+	switch vals[0] {
+	case SYMBOL("and"), SYMBOL("or"):
+		isAnd := vals[0] == SYMBOL("and")
+		for _, v := range vals[1:] {
+			found, err := t.hasFeature(v)
 			if err != nil {
-				return nil, err
+				return false, err
+			}
+			if found != isAnd {
+				return found, nil
 			}
 		}
+		return isAnd, nil
 
-	case "VEC_END":
-		return "VEC_END", nil
+	case SYMBOL("not"):
+		if len(vals) != 2 {
+			return false, fmt.Errorf("Invalid feature expression: %v", String(expr))
+		}
+		found, err := t.hasFeature(vals[1])
+		return !found, err
+
+	default:
+		return false, fmt.Errorf("Invalid feature expression: %v", String(expr))
+	}
+}
+
+// selectBranch picks the form to read from the contents of a #? conditional,
+// returning its index or -1 if no branch applies.
+func (t *Tokeniser) selectBranch(branches []lispVal) (int, error) {
+	if len(branches)%2 != 0 {
+		return -1, fmt.Errorf("`#?` needs an even number of forms")
+	}
+	for i := 0; i < len(branches); i += 2 {
+		name, ok := featureName(branches[i])
+		if !ok {
+			return -1, fmt.Errorf("Invalid feature in `#?`: %v", String(branches[i]))
+		}
+		if t.features[name] || name == "default" {
+			return i + 1, nil
+		}
+	}
+	return -1, nil
+}
+
+// SetFeature adds or removes a feature that reader conditionals can test for
+func (e *Evaluator) SetFeature(name string, present bool) {
+	if present {
+		e.reader.features[name] = true
+	} else {
+		delete(e.reader.features, name)
+	}
+}
+
+// list the features that are present: (features)
+func (e *Evaluator) lispFeatures(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 0 {
+		return nil, fmt.Errorf("features takes no arguments")
+	}
+	names := make([]string, 0, len(e.reader.features))
+	for name := range e.reader.features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	features := make([]lispVal, len(names))
+	for i, name := range names {
+		features[i] = KEYWORD(name)
+	}
+	return List(features...), nil
+}
+
+// Check that a value can be used as a map key or set element
+func checkHashable(v lispVal) error {
+	if v != nil && !reflect.TypeOf(v).Comparable() {
+		return fmt.Errorf("Unable to use %v as a map key or set element", String(v))
+	}
+	return nil
+}
 
-	case "QUOTE", "SPLICE":
-		// Something is being quoted or unquoted
-		quotedList := make([]lispVal, 0)
-		quotedList = append(quotedList, quotes[token.Text])
-		parsed, err := t.parseTokens()
-		if err != nil {
+// Build a MAP from alternating keys and values
+func makeMap(vals []lispVal) (lispVal, error) {
+	if len(vals)%2 != 0 {
+		return nil, fmt.Errorf("Map literal must contain an even number of forms")
+	}
+	m := make(MAP, len(vals)/2)
+	for i := 0; i < len(vals); i += 2 {
+		if err := checkHashable(vals[i]); err != nil {
 			return nil, err
 		}
-		quotedList = append(quotedList, parsed)
-		return List(quotedList...), nil
+		m[vals[i]] = vals[i+1]
+	}
+	return m, nil
+}
 
-	default:
-		// if it"s not a list then it"s an atom
-		return makeAtom(token)
+// Build a SET from its elements
+func makeSet(vals []lispVal) (lispVal, error) {
+	s := make(SET, len(vals))
+	for _, v := range vals {
+		if err := checkHashable(v); err != nil {
+			return nil, err
+		}
+		s[v] = true
 	}
+	return s, nil
 }
 
 // makeAtom determines the correct type for an atom
@@ -183,15 +547,31 @@ func (t *Tokeniser) parseTokens() (lispVal, error) {
 func makeAtom(t token) (lispVal, error) {
 	switch t.Tag {
 	case "STRING":
-		return string(t.Text[1 : len(t.Text)-1]), nil
+		return unescapeString(t.Text[1 : len(t.Text)-1])
 
 	case "INT", "FLOAT":
 		f, _ := strconv.ParseFloat(t.Text, 64)
 		return float64(f), nil
 
+	case "SPECIAL_FLOAT":
+		switch t.Text {
+		case "##NaN":
+			return math.NaN(), nil
+		case "##Inf":
+			return math.Inf(1), nil
+		default:
+			return math.Inf(-1), nil
+		}
+
 	case "COMPLEX", "COMPLEX_PURE":
 		return nil, fmt.Errorf("Complex numbers not implemented yet!")
 
+	case "CHAR":
+		return parseChar(t.Text[2:])
+
+	case "REGEX":
+		return parseRegex(t.Text[2 : len(t.Text)-1])
+
 	case "BOOL":
 		if t.Text == "#t" {
 			return true, nil
@@ -202,9 +582,98 @@ func makeAtom(t token) (lispVal, error) {
 		return KEYWORD(t.Text[1:]), nil
 
 	case "SYMBOL":
+		if t.Text == "nil" {
+			return nil, nil
+		}
+		if isDatumLabel(t.Text) {
+			return nil, fmt.Errorf("Unable to read datum label %v: cyclic values can't be read", t.Text)
+		}
 		return SYMBOL(t.Text), nil
 
 	default:
 		return nil, fmt.Errorf("Unable to parse input: %v", t.Text)
 	}
 }
+
+// isDatumLabel reports whether a symbol is really a #n= or #n# label
+// written by the printer for a cyclic value
+func isDatumLabel(s string) bool {
+	if len(s) < 3 || s[0] != '#' || (s[len(s)-1] != '=' && s[len(s)-1] != '#') {
+		return false
+	}
+	for _, c := range s[1 : len(s)-1] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+	Reader builtins
+*/
+
+// Read the next form from a port, leaving anything after it for the next
+// read. Returns nil at the end of the input.
+func (e *Evaluator) readFromPort(port *InputPort) (lispVal, error) {
+	var buf strings.Builder
+	for {
+		line, err := port.readString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		buf.WriteString(line)
+
+		val, rest, complete, parseErr := e.reader.readFirst(buf.String())
+		if complete {
+			if parseErr != nil {
+				return nil, parseErr
+			}
+			if rest != "" {
+				// Push back whatever followed the form we just read
+				port.unread(rest)
+			}
+			return val, nil
+		}
+
+		if err == io.EOF {
+			if len(e.reader.tokens) == 0 {
+				return nil, nil
+			}
+			return nil, parseErr
+		}
+	}
+}
+
+// parse the first form in a string: (read-string "(+ 1 2)")
+func (e *Evaluator) readString(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("read-string takes a single string")
+	}
+	s, err := getString(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	val, _, complete, err := e.reader.readFirst(s)
+	if err != nil {
+		return nil, err
+	}
+	if !complete {
+		return nil, fmt.Errorf("Nothing to read in: %v", s)
+	}
+	return val, nil
+}
+
+// read a form from a string, a port or the current input port
+func (e *Evaluator) lispRead(lst ...lispVal) (lispVal, error) {
+	if len(lst) == 1 {
+		if _, ok := lst[0].(string); ok {
+			return e.readString(lst...)
+		}
+	}
+	port, err := e.inputPortArg("read", lst)
+	if err != nil {
+		return nil, err
+	}
+	return e.readFromPort(port)
+}
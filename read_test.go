@@ -0,0 +1,95 @@
+package gigl
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	tests := []struct {
+		src   string
+		start Pos
+	}{
+		{"#| unclosed\n(define x 1)", Pos{Offset: 0, Line: 1, Col: 1}},
+		{"(define x 1)\n  #| outer #| inner |#\n(define y 2)", Pos{Offset: 15, Line: 2, Col: 3}},
+	}
+
+	for _, tt := range tests {
+		_, err := NewTokeniser().ReadAll(tt.src)
+		var errs SyntaxErrors
+		if !errors.As(err, &errs) || len(errs) != 1 {
+			t.Errorf("%q: expected a single syntax error, got %v", tt.src, err)
+			continue
+		}
+		got := errs[0]
+		if got.Message != "Unterminated block comment" {
+			t.Errorf("%q: got message %q", tt.src, got.Message)
+		}
+		want := Span{tt.start, tt.start.advance("#|")}
+		if got.Span != want {
+			t.Errorf("%q: got span %v, want %v", tt.src, got.Span, want)
+		}
+
+		if _, err := Format(tt.src); err == nil {
+			t.Errorf("%q: expected Format to fail", tt.src)
+		}
+		if status, _ := NewTokeniser().checkInput(tt.src); status != inputIncomplete {
+			t.Errorf("%q: expected the REPL to wait for more input", tt.src)
+		}
+	}
+
+	// Closed comments are still skipped
+	vals, err := NewTokeniser().ReadAll("#| a #| nested |# comment |# (define x 1)")
+	if err != nil || len(vals) != 1 {
+		t.Errorf("got %v, %v", vals, err)
+	}
+}
+
+// Atoms that fail to parse must not leave a half built value behind for
+// the rest of the reader to trip over
+func TestInvalidAtoms(t *testing.T) {
+	tests := []string{
+		`#"("`,
+		`{[#"("] 1}`,
+		`#{#"("}`,
+		`#+#"(" x`,
+		`#-#"(" x`,
+		`#?(#"(" 1)`,
+		`(a #\nope b)`,
+		`{#\nope 1}`,
+		`#+#\nope x`,
+	}
+
+	tk := NewTokeniser()
+	tk.Tokenise(`#"("`)
+	if val, ok := tk.parseForm(); val != nil || !ok {
+		t.Errorf("parseForm returned %#v, %v", val, ok)
+	}
+
+	for _, src := range tests {
+		var errs SyntaxErrors
+		if _, err := NewTokeniser().ReadAll(src); !errors.As(err, &errs) {
+			t.Errorf("%s: expected a syntax error, got %v", src, err)
+		}
+		if _, err := lowerSource(src); err == nil {
+			t.Errorf("%s: expected an error from the CST", src)
+		}
+		if _, err := Format(src); err == nil {
+			t.Errorf("%s: expected Format to fail", src)
+		}
+
+		e := newTestEvaluator(t)
+		if _, err := evalSource(e, "(read-string "+strconv.Quote(src)+")"); err == nil {
+			t.Errorf("%s: expected read-string to fail", src)
+		}
+	}
+
+	// A bad atom doesn't lead to more errors about the form it is part of
+	for _, src := range []string{`{#"(" 1}`, `#+#"(" x`, `#+(or #"(") x`, `#?(#"(" 1)`, `#?(:gigl #"(")`} {
+		var errs SyntaxErrors
+		if _, err := NewTokeniser().ReadAll(src); !errors.As(err, &errs) || len(errs) != 1 {
+			t.Errorf("%s: expected a single syntax error, got %v", src, err)
+		}
+	}
+}
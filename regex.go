@@ -0,0 +1,271 @@
+package gigl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+/*
+	Regular expressions
+
+	Regexes use Go's RE2 syntax and are read as #"pattern". Anywhere that
+	takes a REGEX will also accept a plain string which is compiled on the
+	fly. Compiled patterns are cached so that doing this inside a loop is
+	cheap.
+*/
+
+// A compiled regular expression, read as #"pattern"
+type REGEX struct {
+	*regexp.Regexp
+}
+
+// Upper bound on the number of patterns we keep hold of
+const regexCacheSize = 512
+
+var regexCache = struct {
+	sync.Mutex
+	patterns map[string]*regexp.Regexp
+}{patterns: make(map[string]*regexp.Regexp)}
+
+// compileRegex compiles a pattern, reusing a cached copy if we have one.
+// The REGEX is only valid if there is no error: callers returning it as a
+// lispVal must return nil instead on error.
+func compileRegex(pattern string) (REGEX, error) {
+	regexCache.Lock()
+	defer regexCache.Unlock()
+
+	if re, ok := regexCache.patterns[pattern]; ok {
+		return REGEX{re}, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return REGEX{}, fmt.Errorf("Invalid regex #%s: %v", quoteString(pattern), err)
+	}
+	if len(regexCache.patterns) >= regexCacheSize {
+		// Not worth being clever here: just start again
+		regexCache.patterns = make(map[string]*regexp.Regexp)
+	}
+	regexCache.patterns[pattern] = re
+	return REGEX{re}, nil
+}
+
+// Parse the body of a #"..." literal. Only \" is treated specially so
+// that everything else is passed through to the regex engine as is.
+func parseRegex(body string) (lispVal, error) {
+	re, err := compileRegex(strings.ReplaceAll(body, `\"`, `"`))
+	if err != nil {
+		return nil, err
+	}
+	return re, nil
+}
+
+// Render a regex in a form that can be read back in. A quote that is
+// already escaped in the pattern is left alone: \" and " match the same
+// thing, so reading it back as " gives an equivalent regex.
+func regexLiteral(re REGEX) string {
+	if re.Regexp == nil {
+		return "#<invalid regex>"
+	}
+	var b strings.Builder
+	b.WriteString(`#"`)
+	escaped := false
+	for _, c := range re.String() {
+		if c == '"' && !escaped {
+			b.WriteByte('\\')
+		}
+		escaped = c == '\\' && !escaped
+		b.WriteRune(c)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// helper to pull a regex out of a lispVal, compiling strings as needed
+func getRegex(l lispVal) (REGEX, error) {
+	switch l := l.(type) {
+	case REGEX:
+		return l, nil
+	case string:
+		return compileRegex(l)
+	default:
+		return REGEX{}, fmt.Errorf("Non-regex argument: %v", String(l))
+	}
+}
+
+// helper for the common (re-xxx re s) argument pattern
+func getRegexAndString(name string, lst []lispVal) (REGEX, string, error) {
+	if len(lst) != 2 {
+		return REGEX{}, "", fmt.Errorf("%s takes a regex and a string", name)
+	}
+	re, err := getRegex(lst[0])
+	if err != nil {
+		return REGEX{}, "", err
+	}
+	s, err := getString(lst[1])
+	if err != nil {
+		return REGEX{}, "", err
+	}
+	return re, s, nil
+}
+
+// Convert a match into a gigl value: the matched string if the regex has
+// no groups, otherwise a vector of the whole match followed by the groups.
+func matchResult(re REGEX, s string, loc []int) lispVal {
+	if re.NumSubexp() == 0 {
+		return s[loc[0]:loc[1]]
+	}
+	groups := make([]lispVal, len(loc)/2)
+	for i := range groups {
+		if loc[2*i] >= 0 {
+			groups[i] = s[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	return groups
+}
+
+func isRegex(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("Type check on non-atom: %v", lst)
+	}
+	_, ok := lst[0].(REGEX)
+	return ok, nil
+}
+
+// compile a string into a regex: (re-pattern "\\d+")
+func rePattern(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("re-pattern takes a single string")
+	}
+	re, err := getRegex(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	return re, nil
+}
+
+// the first match of re in s or nil: (re-find #"\d+" s)
+func reFind(lst ...lispVal) (lispVal, error) {
+	re, s, err := getRegexAndString("re-find", lst)
+	if err != nil {
+		return nil, err
+	}
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil, nil
+	}
+	return matchResult(re, s, loc), nil
+}
+
+// like re-find but re has to match the whole of s
+func reMatches(lst ...lispVal) (lispVal, error) {
+	re, s, err := getRegexAndString("re-matches", lst)
+	if err != nil {
+		return nil, err
+	}
+	anchored, err := compileRegex(`^(?:` + re.String() + `)$`)
+	if err != nil {
+		return nil, err
+	}
+	loc := anchored.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil, nil
+	}
+	return matchResult(re, s, loc), nil
+}
+
+// a lazy sequence of all of the matches of re in s
+func reSeq(lst ...lispVal) (lispVal, error) {
+	re, s, err := getRegexAndString("re-seq", lst)
+	if err != nil {
+		return nil, err
+	}
+
+	// Go can't resume a search part way through a string without losing
+	// the context that ^ and \b depend on, so the whole string is searched
+	// for a growing number of matches whenever we run out.
+	var matches [][]int
+	next, limit := 0, 0
+	return NewLazySeq(func() (lispVal, bool, error) {
+		if next == len(matches) {
+			if len(matches) < limit {
+				return nil, false, nil
+			}
+			limit = 2*limit + 16
+			matches = re.FindAllStringSubmatchIndex(s, limit)
+			if next == len(matches) {
+				return nil, false, nil
+			}
+		}
+		loc := matches[next]
+		next++
+		return matchResult(re, s, loc), true, nil
+	}), nil
+}
+
+// the capture groups of the first match of re in s as a MAP. Named groups
+// are keyed by keyword and all groups are also keyed by their index.
+func reGroups(lst ...lispVal) (lispVal, error) {
+	re, s, err := getRegexAndString("re-groups", lst)
+	if err != nil {
+		return nil, err
+	}
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil, nil
+	}
+
+	groups := make(MAP)
+	for i, name := range re.SubexpNames() {
+		var val lispVal
+		if loc[2*i] >= 0 {
+			val = s[loc[2*i]:loc[2*i+1]]
+		}
+		groups[float64(i)] = val
+		if name != "" {
+			groups[KEYWORD(name)] = val
+		}
+	}
+	return groups, nil
+}
+
+// replace every match of re in s. The replacement is either a string,
+// which may refer to groups using $1 or ${name}, or a procedure which is
+// called with each match (as returned by re-find) and must return a string.
+func reReplace(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 3 {
+		return nil, fmt.Errorf("re-replace takes a regex, a string and a replacement")
+	}
+	re, s, err := getRegexAndString("re-replace", lst[:2])
+	if err != nil {
+		return nil, err
+	}
+
+	if repl, ok := lst[2].(string); ok {
+		return re.ReplaceAllString(s, repl), nil
+	}
+	repl, ok := asProcedure(lst[2])
+	if !ok {
+		return nil, fmt.Errorf("re-replace needs a string or procedure as a replacement: %v", String(lst[2]))
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(s, -1) {
+		result, err := repl(matchResult(re, s, loc))
+		if err != nil {
+			return nil, err
+		}
+		str, err := getString(result)
+		if err != nil {
+			return nil, fmt.Errorf("re-replace procedure must return a string: %v", String(result))
+		}
+		b.WriteString(s[last:loc[0]])
+		b.WriteString(str)
+		last = loc[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
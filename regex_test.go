@@ -0,0 +1,57 @@
+package gigl
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestReSeq(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`(re-seq #"a" "banana")`, `("a" "a" "a")`},
+		{`(re-seq #"^a" "aaa")`, `("a")`},
+		{`(re-seq #"\ba" "a aa")`, `("a" "a")`},
+		{`(re-seq #"a$" "aaa")`, `("a")`},
+		{`(re-seq #"" "é")`, `("" "")`},
+		{`(re-seq #"x*" "éx")`, `("" "x")`},
+		{`(re-seq #"z" "abc")`, `()`},
+		{`(re-seq #"(\d)(\d)" "12 34")`, `(["12" "1" "2"] ["34" "3" "4"])`},
+		{`(len (re-seq #"\d" "1234567890123456789012345678901234567890"))`, `40`},
+		{`(take 2 (re-seq #"\d" "1234"))`, `("1" "2")`},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		got, err := evalSource(e, tt.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if text := String(got); text != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.src, text, tt.want)
+		} else if !utf8.ValidString(text) {
+			t.Errorf("%s: invalid UTF-8 in %q", tt.src, text)
+		}
+	}
+}
+
+func TestInvalidRegex(t *testing.T) {
+	if val, err := parseRegex(`(`); err == nil || val != nil {
+		t.Errorf("parseRegex: got %#v, %v", val, err)
+	}
+	if val, err := rePattern("("); err == nil || val != nil {
+		t.Errorf("rePattern: got %#v, %v", val, err)
+	}
+	if got := String(REGEX{}); got != "#<invalid regex>" {
+		t.Errorf("String(REGEX{}) = %s", got)
+	}
+
+	e := newTestEvaluator(t)
+	for _, src := range []string{`#"("`, `(re-pattern "(")`, `(re-find "(" "x")`} {
+		if _, err := evalSource(e, src); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
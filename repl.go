@@ -2,38 +2,38 @@ package gigl
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/chzyer/readline"
 )
 
 var (
-	InPrompt  = "λ > "
-	OutPrompt = "   "
-	input     string
-	prevInput string
+	InPrompt   = "λ > "
+	ContPrompt = "  > "
+	OutPrompt  = "   "
+	input      string
+	prevInput  string
 )
 
 // REPL is the read-eval-print-loop
 func REPL() {
 	evaluator := NewEvaluator()
-	tokeniser := NewTokeniser()
+	tokeniser := evaluator.reader
 
 	// Load the prelude
 	fmt.Printf("((Welcome to GIGL!)\n  (Loading prelude...)\n")
-	for _, proc := range prelude {
-		parsed, err := tokeniser.read(proc)
-		if err != nil {
-			panic(fmt.Sprintf("\n\nError in prelude!\n%v\n\n", err))
-		}
-		evaluator.eval(parsed, nil)
-	}
+	loadPrelude(evaluator)
 	fmt.Println("  (...done!))")
 
 	rl, err := readline.NewEx(&readline.Config{
-		Prompt:                 InPrompt,
-		HistoryFile:            "/tmp/gigl-repl",
-		DisableAutoSaveHistory: true,
+		Prompt:       InPrompt,
+		AutoComplete: replCompleter{evaluator},
+		HistoryFile:  historyPath(),
 	})
 
 	// If we can't create the REPL we're boned...
@@ -41,57 +41,196 @@ func REPL() {
 		panic(err)
 	}
 	defer rl.Close()
+	s := &replSession{e: evaluator, rl: rl, out: os.Stdout, sigs: make(chan os.Signal, 1)}
+
+	// Ctrl-C while code is running interrupts it rather than killing the
+	// REPL. Readline sees it as ErrInterrupt the rest of the time.
+	signal.Notify(s.sigs, os.Interrupt)
+	defer signal.Stop(s.sigs)
 
-	previousInput := ""
+	// Lines of a form that hasn't been finished yet
+	var lines []string
+	indent := 0
 
-	for {
-		input, err := rl.Readline()
+	for !s.done {
+		line, err := rl.ReadlineWithDefault(strings.Repeat(" ", indent))
+		if err == readline.ErrInterrupt && len(lines) > 0 {
+			// Ctrl-C abandons a half typed form rather than exiting
+			lines, indent = nil, 0
+			rl.SetPrompt(InPrompt)
+			continue
+		}
 		if err != nil {
 			fmt.Println(err)
 			break
 		}
 
-		// Prepend the previous user input if there is any
-		if previousInput != "" {
-			// input = previousInput + " " + strings.TrimSpace(input)
-			input = previousInput + "\n" + input
+		input := strings.Join(append(lines, line), "\n")
+		if strings.TrimSpace(input) == "" {
+			lines, indent = nil, 0
+			continue
 		}
 
-		if len(input) > 0 {
-			if !hasMatchingParens(input) {
-				rl.SetPrompt(OutPrompt)
-				previousInput = input
-				continue
+		if len(lines) == 0 && strings.HasPrefix(strings.TrimSpace(input), ",") {
+			if err := s.command(strings.TrimSpace(input)); err != nil {
+				evaluator.setError(err)
+				fmt.Fprintf(s.out, "ERROR => %v\n\n", err)
 			}
+			continue
+		}
 
-			rl.SetPrompt(InPrompt)
-			rl.SaveHistory(input)
+		var status inputStatus
+		if status, indent = tokeniser.checkInput(input); status == inputIncomplete {
+			lines = append(lines, line)
+			rl.SetPrompt(ContPrompt)
+			continue
+		}
+		lines, indent = nil, 0
+		rl.SetPrompt(InPrompt)
+		s.evalInput(input)
+	}
+}
 
-			parsed, parseErr := tokeniser.read(input)
-			if parseErr != nil {
-				fmt.Printf("PARSE ERROR:\n%v\n=> %v\n\n", input, parseErr)
-				previousInput = ""
-				continue
+// evalInput evaluates each of the forms in a complete input, printing the
+// results and keeping *1, *2, *3 and *e up to date. Evaluation stops at
+// the first error.
+func (s *replSession) evalInput(input string) {
+	forms, positions, parseErr := s.e.reader.readAllPositions(input)
+	if parseErr != nil {
+		s.e.setError(parseErr)
+		fmt.Fprintf(s.out, "PARSE ERROR:\n%v\n=> %v\n\n", input, parseErr)
+		return
+	}
+	ctx, cancel := s.interruptible()
+	defer cancel()
+	for i, parsed := range forms {
+		s.e.sourcePos = fmt.Sprintf("repl:%d:%d", positions[i].Line, positions[i].Col)
+		result, evalErr := s.e.EvalContext(ctx, parsed)
+		if evalErr != nil {
+			s.e.setError(evalErr)
+			fmt.Fprintf(s.out, "ERROR => %v\n\n", evalErr)
+			return
+		}
+		if isDefinition(parsed) {
+			s.entered = append(s.entered, formSource(input, positions, i))
+		}
+		s.e.pushResult(result)
+		printResult(s.out, result)
+	}
+}
+
+// loadPrelude defines the procedures in the prelude along with the
+// *1, *2, *3 and *e bindings used by interactive sessions
+func loadPrelude(e *Evaluator) {
+	for i, proc := range prelude {
+		parsed, err := e.reader.read(proc)
+		if err != nil {
+			panic(fmt.Sprintf("\n\nError in prelude!\n%v\n\n", err))
+		}
+		e.sourcePos = fmt.Sprintf("prelude:%d:1", i+1)
+		e.eval(parsed, nil)
+	}
+	e.sourcePos = ""
+	for _, sym := range []SYMBOL{"*1", "*2", "*3", "*e"} {
+		e.globalEnv.vals[sym] = nil
+	}
+}
+
+// printResult shows the value of a form. Long results are wrapped and
+// indented to line up with the prompt.
+func printResult(w io.Writer, result lispVal) {
+	res := prettyString(result, 80-len(OutPrompt))
+	fmt.Fprintln(w, OutPrompt, strings.ReplaceAll(res, "\n", "\n "+OutPrompt))
+}
+
+// historyPath is where the REPL keeps its history: $GIGL_HISTORY if it is
+// set, otherwise gigl/history in $XDG_STATE_HOME (~/.local/state). History
+// is only kept in memory if neither can be used.
+func historyPath() string {
+	if path := os.Getenv("GIGL_HISTORY"); path != "" {
+		return path
+	}
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	dir = filepath.Join(dir, "gigl")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "history")
+}
+
+// Whether the text typed into the REPL so far can be evaluated
+type inputStatus int
+
+const (
+	inputComplete   inputStatus = iota
+	inputIncomplete             // more lines are needed to finish a form
+	inputInvalid                // there is a syntax error that more input can't fix
+)
+
+// checkInput uses the reader to work out whether input is ready to be
+// evaluated. If it isn't then it also returns the column that the next
+// line should be indented to, following the same rules as the formatter.
+func (t *Tokeniser) checkInput(input string) (inputStatus, int) {
+	t.Tokenise(input)
+	if t.exhausted {
+		// Inside a string or block comment so leave the indentation alone
+		return inputIncomplete, 0
+	}
+
+	var open []int
+	for i, tok := range t.tokens {
+		switch {
+		case closingBrackets[tok.Tag].tag != "":
+			open = append(open, i)
+		case isClosing(tok.Tag):
+			if len(open) == 0 || closingBrackets[t.tokens[open[len(open)-1]].Tag].tag != tok.Tag {
+				return inputInvalid, 0
 			}
-			result, evalErr := evaluator.eval(parsed, nil)
-			if evalErr != nil {
-				fmt.Printf("ERROR => %v\n\n", evalErr)
-				previousInput = ""
-			} else {
-				res := String(result)
-				if res != "" {
-					fmt.Println(OutPrompt, res)
-				}
-				previousInput = ""
+			open = open[:len(open)-1]
+		}
+	}
+
+	if len(open) == 0 {
+		if _, err := t.ReadAll(input); err != nil {
+			// The only error is a prefix such as ' or #+gigl at the end
+			// that is still waiting for its form
+			if t.exhausted && len(t.errors) == 1 {
+				return inputIncomplete, 0
 			}
+			return inputInvalid, 0
 		}
+		return inputComplete, 0
 	}
+	return inputIncomplete, t.continuationIndent(open[len(open)-1])
 }
 
-// Check that we have a complete s-expression
-// NOTE :: This _will_ fail if string literals have unmatched parens...
-func hasMatchingParens(input string) bool {
-	parenOpen := strings.Count(input, "(")
-	parenClose := strings.Count(input, ")")
-	return parenOpen == parenClose
+// continuationIndent is the indentation for a new line inside the
+// collection opened by the token at index i
+func (t *Tokeniser) continuationIndent(i int) int {
+	start := t.tokens[i].Span.Start
+	col := start.Col - 1
+	if t.tokens[i].Tag != "LIST_START" || i+1 >= len(t.tokens) || t.tokens[i+1].Tag != "SYMBOL" {
+		// Data lines up with the first element
+		return col + utf8.RuneCountInString(t.tokens[i].Text)
+	}
+
+	head := t.tokens[i+1]
+	if _, special := specialIndent[SYMBOL(head.Text)]; special {
+		return col + 2
+	}
+	// Arguments line up with the first one if it is on the same line as
+	// the head of the form
+	if i+2 < len(t.tokens) {
+		if arg := t.tokens[i+2]; arg.Span.Start.Line == start.Line {
+			return arg.Span.Start.Col - 1
+		}
+	}
+	return col + 2
 }
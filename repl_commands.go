@@ -0,0 +1,329 @@
+package gigl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chzyer/readline"
+)
+
+/*
+	REPL commands
+
+	Input starting with a comma is a command for the REPL itself rather
+	than code to evaluate:
+
+	  ,doc sym        describe what sym refers to
+	  ,load file      evaluate the forms in a file
+	  ,reload         forget what was loaded and load the same files again
+	  ,env [part]     list the bindings made in this session
+	  ,time expr      evaluate expr and show how long it took
+	  ,expand expr    show expr with its outer macro call expanded
+	  ,clear          clear the screen
+	  ,save file      write the definitions typed in so far to a file
+	  ,inspect expr   walk through the value of expr (see inspect.go)
+	  ,quit           leave the REPL
+
+	After each evaluation *1, *2 and *3 are bound to the last three results
+	and *e to the last error. Ctrl-C while code is running, including
+	,load, ,time and ,inspect, stops it and returns to the prompt.
+*/
+
+// lineReader is the part of a readline.Instance that the REPL commands
+// use, so that tests can stand in for the terminal
+type lineReader interface {
+	Readline() (string, error)
+	SetPrompt(prompt string)
+}
+
+// replSession is the state of a REPL beyond what the evaluator holds
+type replSession struct {
+	e       *Evaluator
+	rl      lineReader
+	out     io.Writer      // where results and messages are shown
+	files   []string       // files loaded with ,load, for ,reload
+	entered []string       // the source of each definition typed in, for ,save
+	sigs    chan os.Signal // interrupts from Ctrl-C
+	done    bool
+}
+
+// command runs a REPL command such as ,doc map
+func (s *replSession) command(input string) error {
+	name, arg := input, ""
+	if i := strings.IndexAny(input, " \t"); i >= 0 {
+		name, arg = input[:i], strings.TrimSpace(input[i+1:])
+	}
+
+	switch name {
+	case ",doc":
+		if arg == "" || strings.ContainsAny(arg, " \t") {
+			return fmt.Errorf(",doc takes a single symbol")
+		}
+		text, err := s.e.docFor(SYMBOL(arg), nil)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(s.out, text)
+	case ",load":
+		if arg == "" {
+			return fmt.Errorf(",load takes a file name")
+		}
+		path := filepath.Clean(arg)
+		ctx, cancel := s.interruptible()
+		defer cancel()
+		if err := s.e.loadFile(ctx, path); err != nil {
+			return err
+		}
+		for _, f := range s.files {
+			if f == path {
+				return nil
+			}
+		}
+		s.files = append(s.files, path)
+	case ",reload":
+		if len(s.files) == 0 {
+			return fmt.Errorf("Nothing to reload: use ,load first")
+		}
+		ctx, cancel := s.interruptible()
+		defer cancel()
+		for _, path := range s.files {
+			s.e.forgetSource(path)
+			if err := s.e.loadFile(ctx, path); err != nil {
+				return err
+			}
+			fmt.Fprintln(s.out, OutPrompt, "Reloaded", path)
+		}
+	case ",env":
+		s.showEnv(arg)
+	case ",time":
+		forms, err := s.readArg(name, arg)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := s.interruptible()
+		defer cancel()
+		for _, form := range forms {
+			start := time.Now()
+			result, err := s.e.EvalContext(ctx, form)
+			elapsed := time.Since(start)
+			if err != nil {
+				return err
+			}
+			s.e.pushResult(result)
+			printResult(s.out, result)
+			fmt.Fprintln(s.out, OutPrompt, "; Elapsed time:", elapsed)
+		}
+	case ",expand":
+		forms, err := s.readArg(name, arg)
+		if err != nil {
+			return err
+		}
+		for _, form := range forms {
+			expanded, err := s.e.macroExpand(form)
+			if err != nil {
+				return err
+			}
+			printResult(s.out, expanded)
+		}
+	case ",clear":
+		readline.ClearScreen(s.out)
+	case ",save":
+		if arg == "" {
+			return fmt.Errorf(",save takes a file name")
+		}
+		if len(s.entered) == 0 {
+			return fmt.Errorf("No definitions to save")
+		}
+		if err := os.WriteFile(arg, []byte(strings.Join(s.entered, "\n\n")+"\n"), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintln(s.out, OutPrompt, "Saved definitions to", arg)
+	case ",inspect":
+		forms, err := s.readArg(name, arg)
+		if err != nil {
+			return err
+		}
+		if len(forms) != 1 {
+			return fmt.Errorf(",inspect takes a single expression")
+		}
+		ctx, cancel := s.interruptible()
+		val, err := s.e.EvalContext(ctx, forms[0])
+		cancel()
+		if err != nil {
+			return err
+		}
+		return s.inspect(clip(arg, 30), val)
+	case ",quit":
+		s.done = true
+	default:
+		return fmt.Errorf("Unknown REPL command: %v (try ,doc ,load ,reload ,env ,time ,expand ,clear ,save ,inspect or ,quit)", name)
+	}
+	return nil
+}
+
+// interruptible returns a context for running code that is cancelled
+// when the user presses Ctrl-C. The caller must cancel it once the code
+// has finished.
+func (s *replSession) interruptible() (context.Context, context.CancelFunc) {
+	// Forget any Ctrl-C pressed while nothing was running
+	for len(s.sigs) > 0 {
+		<-s.sigs
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-s.sigs:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// pushResult binds *1 to the latest result, moving the previous ones
+// along to *2 and *3
+func (e *Evaluator) pushResult(result lispVal) {
+	vals := e.globalEnv.vals
+	vals["*3"], vals["*2"], vals["*1"] = vals["*2"], vals["*1"], result
+}
+
+// setError binds *e to the latest error
+func (e *Evaluator) setError(err error) {
+	e.globalEnv.vals["*e"] = err
+}
+
+// readArg parses the forms following a command
+func (s *replSession) readArg(name, arg string) ([]lispVal, error) {
+	forms, err := s.e.reader.ReadAll(arg)
+	if err != nil {
+		return nil, err
+	}
+	if len(forms) == 0 {
+		return nil, fmt.Errorf("%s takes an expression", name)
+	}
+	return forms, nil
+}
+
+// showEnv lists the bindings that didn't come from the prelude or the
+// builtins, optionally only those containing part
+func (s *replSession) showEnv(part string) {
+	var names []string
+	for sym, meta := range s.e.globalEnv.meta {
+		pos, _ := meta[KEYWORD("source-pos")].(string)
+		if pos == "" || strings.HasPrefix(pos, "prelude:") || !strings.Contains(string(sym), part) {
+			continue
+		}
+		if _, bound := s.e.globalEnv.vals[sym]; bound {
+			names = append(names, string(sym))
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sym := SYMBOL(name)
+		meta := s.e.globalEnv.meta[sym]
+		if params, ok := meta[KEYWORD("arglists")]; ok {
+			fmt.Fprintln(s.out, OutPrompt, usage(sym, params))
+			continue
+		}
+		val := String(s.e.globalEnv.vals[sym])
+		if len(val) > 60 {
+			val = val[:57] + "..."
+		}
+		fmt.Fprintln(s.out, OutPrompt, name, "=", val)
+	}
+}
+
+// isDefinition reports whether a top level form binds a name
+func isDefinition(form lispVal) bool {
+	lst, ok := form.(*LispList)
+	if !ok {
+		return false
+	}
+	switch lst.Head() {
+	case SYMBOL("define"), SYMBOL("defn"), SYMBOL("defmacro"):
+		return true
+	}
+	return false
+}
+
+// formSource is the text of the ith of the forms read from src
+func formSource(src string, positions []Pos, i int) string {
+	end := len(src)
+	if i+1 < len(positions) {
+		end = positions[i+1].Offset
+	}
+	return strings.TrimSpace(src[positions[i].Offset:end])
+}
+
+// loadFile evaluates each of the forms in a file in the global
+// environment, recording where they came from as metadata. Loading stops
+// part way through if ctx is cancelled.
+func (e *Evaluator) loadFile(ctx context.Context, path string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	forms, positions, err := e.reader.readAllPositions(string(src))
+	if err != nil {
+		return err
+	}
+	defer func() { e.sourcePos = "" }()
+	for i, form := range forms {
+		e.sourcePos = fmt.Sprintf("%s:%d:%d", path, positions[i].Line, positions[i].Col)
+		if _, err := e.EvalContext(ctx, form); err != nil {
+			return fmt.Errorf("%s: %w", e.sourcePos, err)
+		}
+	}
+	return nil
+}
+
+// forgetSource removes the global bindings and macros that were defined by
+// a file so that it can be loaded again
+func (e *Evaluator) forgetSource(path string) {
+	fromPath := func(meta MAP) bool {
+		pos, _ := meta[KEYWORD("source-pos")].(string)
+		return strings.HasPrefix(pos, path+":")
+	}
+	for sym, meta := range e.globalEnv.meta {
+		if fromPath(meta) {
+			delete(e.globalEnv.vals, sym)
+			delete(e.globalEnv.meta, sym)
+		}
+	}
+	for sym, meta := range e.macroMeta {
+		if fromPath(meta) {
+			delete(e.macroTable, sym)
+			delete(e.macroMeta, sym)
+		}
+	}
+}
+
+// macroExpand expands a macro call until the form is no longer one
+func (e *Evaluator) macroExpand(form lispVal) (lispVal, error) {
+	for {
+		lst, ok := form.(*LispList)
+		if !ok {
+			return form, nil
+		}
+		sym, ok := lst.Head().(SYMBOL)
+		if !ok {
+			return form, nil
+		}
+		macro, known := e.macroTable[sym]
+		if !known {
+			return form, nil
+		}
+		expanded, err := e.apply(macro, lst.Tail().toSlice())
+		if err != nil {
+			return nil, err
+		}
+		form = expanded
+	}
+}
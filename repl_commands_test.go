@@ -0,0 +1,195 @@
+package gigl
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// scriptedLines stands in for the terminal, returning each line in turn
+// and then io.EOF
+type scriptedLines struct {
+	lines  []string
+	prompt string
+}
+
+func (r *scriptedLines) Readline() (string, error) {
+	if len(r.lines) == 0 {
+		return "", io.EOF
+	}
+	line := r.lines[0]
+	r.lines = r.lines[1:]
+	return line, nil
+}
+
+func (r *scriptedLines) SetPrompt(prompt string) {
+	r.prompt = prompt
+}
+
+// newTestSession returns a REPL session whose output is collected in a
+// buffer, reading any lines that it asks for from lines
+func newTestSession(t *testing.T, lines ...string) (*replSession, *bytes.Buffer) {
+	t.Helper()
+	var out bytes.Buffer
+	e := newTestEvaluator(t)
+	e.SetOutput(&out)
+	return &replSession{
+		e:    e,
+		rl:   &scriptedLines{lines: lines, prompt: InPrompt},
+		out:  &out,
+		sigs: make(chan os.Signal, 1),
+	}, &out
+}
+
+// run evaluates input or runs it as a command as the REPL would, returning
+// what was written
+func (s *replSession) run(t *testing.T, out *bytes.Buffer, input string) string {
+	t.Helper()
+	out.Reset()
+	if strings.HasPrefix(input, ",") {
+		if err := s.command(input); err != nil {
+			t.Fatalf("%s: unexpected error: %v", input, err)
+		}
+	} else {
+		s.evalInput(input)
+	}
+	return out.String()
+}
+
+func TestResultHistory(t *testing.T) {
+	s, out := newTestSession(t)
+	for _, input := range []string{"1", "(+ 1 1) 3"} {
+		s.run(t, out, input)
+	}
+	if got := s.run(t, out, "(list *1 *2 *3)"); got != OutPrompt+" (3 2 1)\n" {
+		t.Errorf("*1 *2 *3 were %q", got)
+	}
+	// Looking at them is itself a result
+	if got := s.run(t, out, "*1"); got != OutPrompt+" (3 2 1)\n" {
+		t.Errorf("*1 was %q", got)
+	}
+	if got := s.run(t, out, "*2"); got != OutPrompt+" (3 2 1)\n" {
+		t.Errorf("*2 was %q", got)
+	}
+
+	// Errors are kept in *e and don't move the results along
+	if got := s.run(t, out, "(+ 1 :a) 99"); !strings.HasPrefix(got, "ERROR => ") {
+		t.Errorf("error was shown as %q", got)
+	}
+	if got := s.run(t, out, "(list *1 *3)"); got != OutPrompt+" ((3 2 1) (3 2 1))\n" {
+		t.Errorf("after an error *1 *3 were %q", got)
+	}
+	if _, ok := s.e.globalEnv.vals["*e"].(error); !ok {
+		t.Errorf("*e is %v", s.e.globalEnv.vals["*e"])
+	}
+	s.run(t, out, "(")
+	if err, _ := s.e.globalEnv.vals["*e"].(error); err == nil || !strings.Contains(err.Error(), "Unclosed") {
+		t.Errorf("parse error: *e is %v", err)
+	}
+}
+
+func TestReplCommands(t *testing.T) {
+	s, out := newTestSession(t)
+
+	if got := s.run(t, out, ",doc car"); got != "car\n  (car lst)\n  Builtin procedure\n\n  The first element of a list.\n" {
+		t.Errorf(",doc car wrote %q", got)
+	}
+
+	got := s.run(t, out, ",time (+ 1 2)")
+	if !strings.HasPrefix(got, OutPrompt+" 3\n"+OutPrompt+" ; Elapsed time: ") {
+		t.Errorf(",time wrote %q", got)
+	}
+	if s.e.globalEnv.vals["*1"] != 3.0 {
+		t.Errorf(",time didn't set *1: %v", s.e.globalEnv.vals["*1"])
+	}
+
+	s.run(t, out, `(defmacro my-unless (c body) (list 'if c nil body))`)
+	if got := s.run(t, out, ",expand (my-unless (f) (g))"); got != OutPrompt+" (if (f) nil (g))\n" {
+		t.Errorf(",expand wrote %q", got)
+	}
+	if got := s.run(t, out, ",expand (f x)"); got != OutPrompt+" (f x)\n" {
+		t.Errorf(",expand of a call wrote %q", got)
+	}
+
+	s.run(t, out, `(define answer 42) (defn twice (x) (* x 2)) (define long-name "`+strings.Repeat("x", 70)+`")`)
+	want := OutPrompt + " answer = 42\n" +
+		OutPrompt + ` long-name = "` + strings.Repeat("x", 56) + "...\n" +
+		OutPrompt + " (twice x)\n"
+	if got := s.run(t, out, ",env"); got != want {
+		t.Errorf(",env wrote %q, want %q", got, want)
+	}
+	if got := s.run(t, out, ",env tw"); got != OutPrompt+" (twice x)\n" {
+		t.Errorf(",env tw wrote %q", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "session.ggl")
+	if got := s.run(t, out, ",save "+path); got != OutPrompt+" Saved definitions to "+path+"\n" {
+		t.Errorf(",save wrote %q", got)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSaved := "(defmacro my-unless (c body) (list 'if c nil body))\n\n(define answer 42)\n\n(defn twice (x) (* x 2))\n\n" +
+		`(define long-name "` + strings.Repeat("x", 70) + "\")\n"
+	if string(saved) != wantSaved {
+		t.Errorf(",save wrote the file %q, want %q", saved, wantSaved)
+	}
+
+	if s.run(t, out, ",quit"); !s.done {
+		t.Errorf(",quit didn't end the session")
+	}
+}
+
+func TestReplLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.ggl")
+	write := func(src string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("(define lib-a 1)\n(defn lib-f (x) x)\n")
+
+	s, out := newTestSession(t)
+	if got := s.run(t, out, ",load "+path); got != "" {
+		t.Errorf(",load wrote %q", got)
+	}
+	if pos := s.e.globalEnv.meta["lib-f"][KEYWORD("source-pos")]; pos != path+":2:1" {
+		t.Errorf("lib-f was defined at %v", pos)
+	}
+
+	// Definitions that were removed from the file go away on reload
+	write("(define lib-a 2)\n")
+	if got := s.run(t, out, ",reload"); got != OutPrompt+" Reloaded "+path+"\n" {
+		t.Errorf(",reload wrote %q", got)
+	}
+	if got := s.run(t, out, "lib-a"); got != OutPrompt+" 2\n" {
+		t.Errorf("lib-a is %q after reloading", got)
+	}
+	if _, ok := s.e.globalEnv.vals["lib-f"]; ok {
+		t.Errorf("lib-f is still defined after reloading")
+	}
+	if got := s.run(t, out, ",reload"); got != OutPrompt+" Reloaded "+path+"\n" || len(s.files) != 1 {
+		t.Errorf("reloading again wrote %q with files %q", got, s.files)
+	}
+}
+
+func TestReplCommandErrors(t *testing.T) {
+	for _, input := range []string{
+		",doc", ",doc a b", ",doc no-such-thing",
+		",load", ",load no/such/file.ggl", ",reload",
+		",time", ",time (car 1)", ",expand", ",expand (",
+		",save", ",save out.ggl",
+		",inspect", ",inspect 1 2", ",inspect (car 1)",
+		",nope",
+	} {
+		s, _ := newTestSession(t)
+		if err := s.command(input); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package gigl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckInput(t *testing.T) {
+	tests := []struct {
+		input  string
+		status inputStatus
+		indent int
+	}{
+		// Complete
+		{"1", inputComplete, 0},
+		{"(+ 1 2)", inputComplete, 0},
+		{"(+ 1 2) (foo)", inputComplete, 0},
+		{"[1 {:a #{2}}]", inputComplete, 0},
+		{"'x", inputComplete, 0},
+		{"(a ; (unclosed in a comment\n)", inputComplete, 0},
+		{`"(" ")"`, inputComplete, 0},
+		{`#\(`, inputComplete, 0},
+		{"#_ x y", inputComplete, 0},
+
+		// Incomplete, lining up with the first argument
+		{"(+ 1", inputIncomplete, 3},
+		{"(foo bar\n  baz", inputIncomplete, 5},
+		{"  (foo bar", inputIncomplete, 7},
+		{"(a (b c", inputIncomplete, 6},
+		{"(a (b c)", inputIncomplete, 3},
+		{"(λ→ x", inputIncomplete, 4},
+
+		// Incomplete, with the first argument on the next line
+		{"(foo", inputIncomplete, 2},
+		{"(foo\n  bar", inputIncomplete, 2},
+		{"((f x)", inputIncomplete, 1},
+		{"(1 2", inputIncomplete, 1},
+
+		// Incomplete special forms indent by two
+		{"(define x", inputIncomplete, 2},
+		{"(let ((x 1))", inputIncomplete, 2},
+		{"(let ((x 1)", inputIncomplete, 6},
+		{"(defn f (x)\n  (if x", inputIncomplete, 6},
+		{"(defn f (x)\n  (when x", inputIncomplete, 4},
+		{"  (lambda (x)", inputIncomplete, 4},
+
+		// Incomplete data lines up with the first element
+		{"[1", inputIncomplete, 1},
+		{"{:a 1", inputIncomplete, 1},
+		{"#{:a", inputIncomplete, 2},
+		{"(foo [1\n", inputIncomplete, 6},
+
+		// Incomplete with nothing to line up with
+		{"'", inputIncomplete, 0},
+		{"(a) '", inputIncomplete, 0},
+		{"#_", inputIncomplete, 0},
+		{"#+gigl", inputIncomplete, 0},
+		{"#+gigl #_ a", inputIncomplete, 0},
+		{"#_ #_ a", inputIncomplete, 0},
+		{"'#_ a", inputIncomplete, 0},
+		{`"unterminated`, inputIncomplete, 0},
+		{`(print "a`, inputIncomplete, 0},
+		{"#| block", inputIncomplete, 0},
+
+		// Errors that more input can't fix
+		{")", inputInvalid, 0},
+		{"(a))", inputInvalid, 0},
+		{"(a]", inputInvalid, 0},
+		{"[a)", inputInvalid, 0},
+		{"(a (b) c]", inputInvalid, 0},
+		{`#\nope`, inputInvalid, 0},
+		{"(#_)", inputInvalid, 0},
+		{"(#_) '", inputInvalid, 0},
+		{"#0=(a)", inputInvalid, 0},
+	}
+
+	for _, tt := range tests {
+		status, indent := NewTokeniser().checkInput(tt.input)
+		if status != tt.status || indent != tt.indent {
+			t.Errorf("%q: got status %d indent %d, want status %d indent %d",
+				tt.input, status, indent, tt.status, tt.indent)
+		}
+	}
+}
+
+// Each line of formatted code starts where the REPL would have put the
+// cursor after the lines before it
+func TestContinuationIndentMatchesFormat(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "fmt", "*.golden"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no golden files: %v", err)
+	}
+
+	for _, path := range files {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(string(src), "\n")
+		start := 0
+		for i := 1; i < len(lines); i++ {
+			tok := NewTokeniser()
+			status, indent := tok.checkInput(strings.Join(lines[start:i], "\n"))
+			if status != inputIncomplete {
+				start = i
+				continue
+			}
+			if tok.exhausted {
+				// Inside a string or block comment
+				continue
+			}
+			got := len(lines[i]) - len(strings.TrimLeft(lines[i], " "))
+			if got != indent {
+				t.Errorf("%s:%d: line is indented by %d but the REPL would indent it by %d",
+					path, i+1, got, indent)
+			}
+		}
+	}
+}
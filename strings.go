@@ -0,0 +1,550 @@
+package gigl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+	String handling builtins
+
+	:: NOTE ::
+	Strings are stored as Go strings but all indexing and lengths are
+	measured in runes rather than bytes so that unicode text behaves.
+*/
+
+// helper to pull a string out of a lispVal
+func getString(l lispVal) (string, error) {
+	s, ok := l.(string)
+	if !ok {
+		return "", fmt.Errorf("Non-string argument: %v", String(l))
+	}
+	return s, nil
+}
+
+// helper to pull an integer index out of a lispVal
+func getIndex(l lispVal) (int, error) {
+	f, err := getFloat(l)
+	if err != nil {
+		return 0, err
+	}
+	if float64(int64(f)) != f {
+		return 0, fmt.Errorf("Non-integer index: %v", String(l))
+	}
+	return int(f), nil
+}
+
+// helper to pull a slice of strings out of a list or vector
+func getStrings(l lispVal) ([]string, error) {
+	var vals []lispVal
+	switch l := l.(type) {
+	case *LispList:
+		vals = l.toSlice()
+	case []lispVal:
+		vals = l
+	default:
+		return nil, fmt.Errorf("Expected a list of strings: %v", String(l))
+	}
+
+	strs := make([]string, len(vals))
+	for i, v := range vals {
+		s, err := getString(v)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = s
+	}
+	return strs, nil
+}
+
+// Escape a string so that it can be read back in by the tokeniser
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if strconv.IsPrint(r) {
+				b.WriteRune(r)
+			} else {
+				fmt.Fprintf(&b, `\u{%x}`, r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Expand the escape sequences in the body of a string literal
+func unescapeString(s string) (string, error) {
+	if !strings.ContainsRune(s, '\\') {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("Unterminated escape sequence in string: %q", s)
+		}
+
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '0':
+			b.WriteByte(0)
+		case '"', '\\':
+			b.WriteByte(s[i])
+		case 'u':
+			// \u{1F600}
+			end := strings.IndexByte(s[i:], '}')
+			if i+1 >= len(s) || s[i+1] != '{' || end < 0 {
+				return "", fmt.Errorf("Invalid unicode escape in string: %q", s)
+			}
+			code, err := strconv.ParseUint(s[i+2:i+end], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf("Invalid unicode escape in string: %q", s)
+			}
+			b.WriteRune(rune(code))
+			i += end
+		default:
+			return "", fmt.Errorf("Unknown escape sequence `\\%c` in string: %q", s[i], s)
+		}
+	}
+	return b.String(), nil
+}
+
+// extract a rune based substring: (substring s start [end])
+func substring(lst ...lispVal) (lispVal, error) {
+	if len(lst) < 2 || len(lst) > 3 {
+		return nil, fmt.Errorf("substring takes a string, a start and an optional end")
+	}
+	s, err := getString(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+
+	start, err := getIndex(lst[1])
+	if err != nil {
+		return nil, err
+	}
+	end := len(runes)
+	if len(lst) == 3 {
+		end, err = getIndex(lst[2])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if start < 0 || end > len(runes) || start > end {
+		return nil, fmt.Errorf("substring indices out of range: [%d:%d] of %d", start, end, len(runes))
+	}
+	return string(runes[start:end]), nil
+}
+
+// the character at a given rune index: (string-ref s i)
+func stringRef(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 2 {
+		return nil, fmt.Errorf("string-ref takes a string and an index")
+	}
+	s, err := getString(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	i, err := getIndex(lst[1])
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	if i < 0 || i >= len(runes) {
+		return nil, fmt.Errorf("string-ref index out of range: %d of %d", i, len(runes))
+	}
+	return CHAR(runes[i]), nil
+}
+
+// split a string on a separator, or on whitespace if none is given
+func stringSplit(lst ...lispVal) (lispVal, error) {
+	if len(lst) < 1 || len(lst) > 2 {
+		return nil, fmt.Errorf("string-split takes a string and an optional separator")
+	}
+	s, err := getString(lst[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []string
+	if len(lst) == 1 {
+		parts = strings.Fields(s)
+	} else {
+		sep, err := getString(lst[1])
+		if err != nil {
+			return nil, err
+		}
+		parts = strings.Split(s, sep)
+	}
+
+	vals := make([]lispVal, len(parts))
+	for i, p := range parts {
+		vals[i] = p
+	}
+	return List(vals...), nil
+}
+
+// join a list of strings with an optional separator
+func stringJoin(lst ...lispVal) (lispVal, error) {
+	if len(lst) < 1 || len(lst) > 2 {
+		return nil, fmt.Errorf("string-join takes a list of strings and an optional separator")
+	}
+	strs, err := getStrings(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	sep := ""
+	if len(lst) == 2 {
+		sep, err = getString(lst[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return strings.Join(strs, sep), nil
+}
+
+// replace all occurrences of old with new: (string-replace s old new)
+func stringReplace(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 3 {
+		return nil, fmt.Errorf("string-replace takes a string, a target and a replacement")
+	}
+	strs, err := getStrings(List(lst...))
+	if err != nil {
+		return nil, err
+	}
+	return strings.ReplaceAll(strs[0], strs[1], strs[2]), nil
+}
+
+// build a builtin from a simple string -> string function
+func stringMapper(name string, f func(string) string) func(...lispVal) (lispVal, error) {
+	return func(lst ...lispVal) (lispVal, error) {
+		if len(lst) != 1 {
+			return nil, fmt.Errorf("%s takes a single string", name)
+		}
+		s, err := getString(lst[0])
+		if err != nil {
+			return nil, err
+		}
+		return f(s), nil
+	}
+}
+
+// build a builtin from a simple (string, string) -> bool function
+func stringPredicate(name string, f func(string, string) bool) func(...lispVal) (lispVal, error) {
+	return func(lst ...lispVal) (lispVal, error) {
+		if len(lst) != 2 {
+			return nil, fmt.Errorf("%s takes two strings", name)
+		}
+		strs, err := getStrings(List(lst...))
+		if err != nil {
+			return nil, err
+		}
+		return f(strs[0], strs[1]), nil
+	}
+}
+
+// formatArg adapts a lispVal so that it can be used with Go's format verbs:
+// integral numbers work with %d and friends and strings print raw with %s.
+// Format can't return an error so one is recorded in err instead.
+type formatArg struct {
+	val lispVal
+	err *error
+}
+
+func (a formatArg) Format(f fmt.State, verb rune) {
+	spec := fmt.FormatString(f, verb)
+	switch val := a.val.(type) {
+	case float64:
+		switch verb {
+		case 'd', 'x', 'X', 'o', 'O', 'b', 'c', 'U':
+			if float64(int64(val)) != val {
+				if *a.err == nil {
+					*a.err = fmt.Errorf("format: %%%c needs an integer: %v", verb, String(val))
+				}
+				return
+			}
+			fmt.Fprintf(f, spec, int64(val))
+		case 'v', 's':
+			fmt.Fprintf(f, "%"+spec[1:len(spec)-1]+"s", String(val))
+		default:
+			fmt.Fprintf(f, spec, val)
+		}
+	case string:
+		fmt.Fprintf(f, spec, val)
+	default:
+		switch verb {
+		case 'v', 's':
+			fmt.Fprintf(f, "%"+spec[1:len(spec)-1]+"s", String(val))
+		default:
+			fmt.Fprintf(f, spec, val)
+		}
+	}
+}
+
+// format a string using Go's fmt verbs: (format "%s has %d items" name n)
+func format(lst ...lispVal) (lispVal, error) {
+	if len(lst) < 1 {
+		return nil, fmt.Errorf("format requires a format string")
+	}
+	f, err := getString(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	var argErr error
+	args := make([]interface{}, len(lst)-1)
+	for i, v := range lst[1:] {
+		args[i] = formatArg{v, &argErr}
+	}
+	s := fmt.Sprintf(f, args...)
+	if argErr != nil {
+		return nil, argErr
+	}
+	return s, nil
+}
+
+// parse a number from a string, returning #f if it isn't one. An invalid
+// radix is an error, as it is for number->string.
+func stringToNumber(lst ...lispVal) (lispVal, error) {
+	if len(lst) < 1 || len(lst) > 2 {
+		return nil, fmt.Errorf("string->number takes a string and an optional radix")
+	}
+	s, err := getString(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	s = strings.TrimSpace(s)
+
+	if len(lst) == 2 {
+		radix, err := getIndex(lst[1])
+		if err != nil {
+			return nil, err
+		}
+		if radix < 2 || radix > 36 {
+			return nil, fmt.Errorf("Invalid radix: %d", radix)
+		}
+		i, err := strconv.ParseInt(s, radix, 64)
+		if err != nil {
+			return false, nil
+		}
+		return float64(i), nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return false, nil
+	}
+	return f, nil
+}
+
+// render a number as a string with an optional radix for integers
+func numberToString(lst ...lispVal) (lispVal, error) {
+	if len(lst) < 1 || len(lst) > 2 {
+		return nil, fmt.Errorf("number->string takes a number and an optional radix")
+	}
+	f, err := getFloat(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(lst) == 2 {
+		radix, err := getIndex(lst[1])
+		if err != nil {
+			return nil, err
+		}
+		if float64(int64(f)) != f {
+			return nil, fmt.Errorf("number->string can only use a radix with integers: %v", String(f))
+		}
+		if radix < 2 || radix > 36 {
+			return nil, fmt.Errorf("Invalid radix: %d", radix)
+		}
+		return strconv.FormatInt(int64(f), radix), nil
+	}
+	return String(f), nil
+}
+
+/*
+	Characters
+*/
+
+// Named characters that can be read as #\name
+var charNames = map[string]CHAR{
+	"space":     ' ',
+	"newline":   '\n',
+	"tab":       '\t',
+	"return":    '\r',
+	"nul":       0,
+	"null":      0,
+	"alarm":     '\a',
+	"backspace": '\b',
+	"delete":    0x7f,
+	"escape":    0x1b,
+}
+
+// Parse the text following #\ in a character literal
+func parseChar(text string) (lispVal, error) {
+	if utf8.RuneCountInString(text) == 1 {
+		r, _ := utf8.DecodeRuneInString(text)
+		return CHAR(r), nil
+	}
+	if c, ok := charNames[text]; ok {
+		return c, nil
+	}
+	if text[0] == 'x' {
+		code, err := strconv.ParseUint(text[1:], 16, 32)
+		if err == nil && utf8.ValidRune(rune(code)) {
+			return CHAR(code), nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown character: #\\%s", text)
+}
+
+// Render a character in a form that can be read back in
+func charLiteral(c CHAR) string {
+	for name, named := range charNames {
+		if c == named && name != "null" {
+			return "#\\" + name
+		}
+	}
+	if !strconv.IsPrint(rune(c)) {
+		return fmt.Sprintf("#\\x%x", rune(c))
+	}
+	return "#\\" + string(rune(c))
+}
+
+// helper to pull a character out of a lispVal
+func getChar(l lispVal) (CHAR, error) {
+	c, ok := l.(CHAR)
+	if !ok {
+		return 0, fmt.Errorf("Non-character argument: %v", String(l))
+	}
+	return c, nil
+}
+
+func isChar(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("Type check on non-atom: %v", lst)
+	}
+	_, ok := lst[0].(CHAR)
+	return ok, nil
+}
+
+func charToInteger(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("char->integer takes a single character")
+	}
+	c, err := getChar(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	return float64(c), nil
+}
+
+func integerToChar(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("integer->char takes a single integer")
+	}
+	i, err := getIndex(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.ValidRune(rune(i)) {
+		return nil, fmt.Errorf("Invalid unicode code point: %d", i)
+	}
+	return CHAR(i), nil
+}
+
+// build a builtin from a simple rune -> rune function
+func charMapper(name string, f func(rune) rune) func(...lispVal) (lispVal, error) {
+	return func(lst ...lispVal) (lispVal, error) {
+		if len(lst) != 1 {
+			return nil, fmt.Errorf("%s takes a single character", name)
+		}
+		c, err := getChar(lst[0])
+		if err != nil {
+			return nil, err
+		}
+		return CHAR(f(rune(c))), nil
+	}
+}
+
+// build a builtin from a simple rune -> bool function
+func charPredicate(name string, f func(rune) bool) func(...lispVal) (lispVal, error) {
+	return func(lst ...lispVal) (lispVal, error) {
+		if len(lst) != 1 {
+			return nil, fmt.Errorf("%s takes a single character", name)
+		}
+		c, err := getChar(lst[0])
+		if err != nil {
+			return nil, err
+		}
+		return f(rune(c)), nil
+	}
+}
+
+// split a string into a list of characters
+func stringToList(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("string->list takes a single string")
+	}
+	s, err := getString(lst[0])
+	if err != nil {
+		return nil, err
+	}
+	chars := make([]lispVal, 0, len(s))
+	for _, r := range s {
+		chars = append(chars, CHAR(r))
+	}
+	return List(chars...), nil
+}
+
+// build a string from a list of characters
+func listToString(lst ...lispVal) (lispVal, error) {
+	if len(lst) != 1 {
+		return nil, fmt.Errorf("list->string takes a single list of characters")
+	}
+	var vals []lispVal
+	switch l := lst[0].(type) {
+	case *LispList:
+		vals = l.toSlice()
+	case []lispVal:
+		vals = l
+	default:
+		return nil, fmt.Errorf("Expected a list of characters: %v", String(l))
+	}
+
+	var b strings.Builder
+	for _, v := range vals {
+		c, err := getChar(v)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteRune(rune(c))
+	}
+	return b.String(), nil
+}
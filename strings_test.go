@@ -0,0 +1,196 @@
+package gigl
+
+import "testing"
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		// Escapes
+		{`"a\nb"`, `"a\nb"`},
+		{`(len "a\nb\t\"\\")`, `6`},
+		{`(string->list "\"\\")`, `(#\" #\\)`},
+		{`(eq? "\u{3bb}" "λ")`, `#t`},
+		{`(string->list "\u{41}\u{1F600}")`, `(#\A #\😀)`},
+
+		// Length and indexing count runes rather than bytes
+		{`(len "")`, `0`},
+		{`(len "λx→")`, `3`},
+		{`(len "😀")`, `1`},
+		{`(string-ref "aλb" 1)`, `#\λ`},
+		{`(string-ref "aλb" 2)`, `#\b`},
+
+		{`(substring "hello" 1 3)`, `"el"`},
+		{`(substring "hello" 2)`, `"llo"`},
+		{`(substring "λμνξ" 1 3)`, `"μν"`},
+		{`(substring "abc" 3)`, `""`},
+
+		{`(string-split "a,b,,c" ",")`, `("a" "b" "" "c")`},
+		{`(string-split "  a  b\tc\n")`, `("a" "b" "c")`},
+		{`(string-split "" ",")`, `("")`},
+		{`(string-join '("a" "b" "c") ", ")`, `"a, b, c"`},
+		{`(string-join '("a" "b"))`, `"ab"`},
+		{`(string-join '())`, `""`},
+		{`(string-replace "banana" "an" "AN")`, `"bANANa"`},
+		{`(string-replace "aaa" "b" "c")`, `"aaa"`},
+
+		{`(upcase "λx")`, `"ΛX"`},
+		{`(downcase "ΛX")`, `"λx"`},
+		{`(trim "  x y \n")`, `"x y"`},
+		{`(starts-with? "gigl" "gi")`, `#t`},
+		{`(ends-with? "gigl" "gi")`, `#f`},
+		{`(contains? "gigl" "ig")`, `#t`},
+
+		{`(format "%s has %d items" "list" 3)`, `"list has 3 items"`},
+		{`(format "%v %v" :k '(1 "a"))`, `":k (1 \"a\")"`},
+		{`(format "%5.2f|%-4d|%x" 3.14159 7 255)`, `" 3.14|7   |ff"`},
+		{`(format "%q" "a\"b")`, `"\"a\\\"b\""`},
+		{`(format "%s" 1.5)`, `"1.5"`},
+		{`(format "100%%")`, `"100%"`},
+
+		{`(string->number "42")`, `42`},
+		{`(string->number " -1.5e3 ")`, `-1500`},
+		{`(string->number "ff" 16)`, `255`},
+		{`(string->number "12x")`, `#f`},
+		{`(string->number "2" 2)`, `#f`},
+		{`(number->string 255 16)`, `"ff"`},
+		{`(number->string 1.5)`, `"1.5"`},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		got, err := evalSource(e, tt.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if String(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.src, String(got), tt.want)
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`(len)`, `len takes a single argument`},
+		{`(len "a" "b")`, `len takes a single argument`},
+		{`(substring "abc" 2 1)`, `substring indices out of range: [2:1] of 3`},
+		{`(substring "λ" 0 2)`, `substring indices out of range: [0:2] of 1`},
+		{`(format "%d" 1.5)`, `format: %d needs an integer: 1.5`},
+		{`(format "%x" 0.1)`, `format: %x needs an integer: 0.1`},
+		{`(string->number "10" 1)`, `Invalid radix: 1`},
+		{`(string->number "10" 37)`, `Invalid radix: 37`},
+		{`(number->string 10 1)`, `Invalid radix: 1`},
+		{`(number->string 1.5 2)`, `number->string can only use a radix with integers: 1.5`},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		_, err := evalSource(e, tt.src)
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.src)
+			continue
+		}
+		if err.Error() != tt.want {
+			t.Errorf("%s: got error %q, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestCharLiterals(t *testing.T) {
+	tests := []struct {
+		src     string
+		want    CHAR
+		printed string
+	}{
+		{`#\a`, 'a', `#\a`},
+		{`#\space`, ' ', `#\space`},
+		{`#\newline`, '\n', `#\newline`},
+		{`#\tab`, '\t', `#\tab`},
+		{`#\x41`, 'A', `#\A`},
+		{`#\x3bb`, 'λ', `#\λ`},
+		{`#\λ`, 'λ', `#\λ`},
+		{`#\x`, 'x', `#\x`},
+		{`#\(`, '(', `#\(`},
+		{`#\x0`, 0, `#\nul`},
+		{`#\x200b`, 0x200b, `#\x200b`},
+	}
+
+	for _, tt := range tests {
+		val, err := NewTokeniser().read(tt.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if val != tt.want {
+			t.Errorf("%s: read %#v, want %#v", tt.src, val, tt.want)
+		}
+		printed := String(val)
+		if printed != tt.printed {
+			t.Errorf("%s: printed as %s, want %s", tt.src, printed, tt.printed)
+		}
+		again, err := NewTokeniser().read(printed)
+		if err != nil || again != val {
+			t.Errorf("%s: %s read back as %#v, %v", tt.src, printed, again, err)
+		}
+	}
+
+	for _, src := range []string{`#\nope`, `#\xzz`, `#\x110000`} {
+		if _, err := NewTokeniser().read(src); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
+
+func TestChars(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`(char->integer #\a)`, `97`},
+		{`(char->integer #\λ)`, `955`},
+		{`(char->integer #\newline)`, `10`},
+		{`(integer->char 65)`, `#\A`},
+		{`(integer->char 32)`, `#\space`},
+		{`(integer->char (char->integer #\😀))`, `#\😀`},
+		{`(list->string '(#\a #\λ #\space #\b))`, `"aλ b"`},
+		{`(list->string '())`, `""`},
+		{`(list->string [#\x #\y])`, `"xy"`},
+		{`(list->string (string->list "héllo"))`, `"héllo"`},
+		{`(char-upcase #\λ)`, `#\Λ`},
+		{`(char-alphabetic? #\λ)`, `#t`},
+		{`(char-numeric? #\a)`, `#f`},
+		{`(char? #\a)`, `#t`},
+		{`(char? "a")`, `#f`},
+	}
+
+	for _, tt := range tests {
+		e := newTestEvaluator(t)
+		got, err := evalSource(e, tt.src)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.src, err)
+			continue
+		}
+		if String(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.src, String(got), tt.want)
+		}
+	}
+
+	for _, src := range []string{
+		`(char->integer "a")`,
+		`(integer->char -1)`,
+		`(integer->char 1114112)`,
+		`(integer->char 1.5)`,
+		`(list->string '(#\a "b"))`,
+		`(list->string "ab")`,
+	} {
+		e := newTestEvaluator(t)
+		if _, err := evalSource(e, src); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
@@ -1,7 +1,5 @@
 package gigl
 
-import "fmt"
-
 /*
 	Type constructors and helper functions for the REPL
 */
@@ -25,6 +23,9 @@ type SYMBOL string
 
 type KEYWORD string
 
+// A single unicode character, read as #\a
+type CHAR rune
+
 type VECTOR []lispVal
 
 type MAP map[lispVal]lispVal
@@ -96,41 +97,15 @@ func makeProc(params, body lispVal, env *environment, e *Evaluator) (func(...lis
 	return proc, nil
 }
 
-// Convert a lispVal to a string
-func String(val lispVal) string {
+// isTruthy reports whether a value counts as true in a conditional:
+// everything other than #f and nil is truthy.
+func isTruthy(val lispVal) bool {
 	switch val := val.(type) {
-	case LispList:
-		return val.String()
-
-	case SYMBOL:
-		return fmt.Sprintf("%v", val)
-
-	case string:
-		return fmt.Sprintf("\"%v\"", val)
-
-	case KEYWORD:
-		return fmt.Sprintf(":%v", val)
-
-	case float64:
-		// Try to print ints correctly
-		i := int64(val)
-		if float64(i) == val {
-			return fmt.Sprint(i)
-		} else {
-			return fmt.Sprint(val)
-		}
-
 	case bool:
-		if val {
-			return "#t"
-		}
-		return "#f"
-
+		return val
 	case nil:
-		return ""
-
+		return false
 	default:
-		// Try to just print the value
-		return fmt.Sprint(val)
+		return true
 	}
 }